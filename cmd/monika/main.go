@@ -1,17 +1,137 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	monika "hyperjumptech/monika/internal/monika"
 )
 
+const version = "v0.0.1"
+
+const usage = `Usage: monika [command] [flags]
+
+Commands:
+  run                            Run the probes and reload them when the config file changes (default)
+  validate                       Validate the config file and exit with a non-zero code on errors
+  probe <probe-id|name>          Run a single probe once and print the result
+  notify-test <notification-id>  Send a test message through a single notification channel
+  replay                         Send the undelivered notifications of the dead letter file again
+  version                        Print the version of Monika
+  help                           Print this help
+
+Flags:
+  -c, --config <path>            Path to config file (default "monika.yml")
+`
+
 func main() {
+	// Running without a command, e.g. "monika -c monika.yml", runs the probes
+	command := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	switch command {
+	case "run":
+		configPath, _ := parseFlags(command, args)
+		printBanner()
+		monika.Run(configPath)
+
+		// Run until interrupted, then save the notifications still waiting to be delivered
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		monika.Stop()
+	case "validate":
+		configPath, _ := parseFlags(command, args)
+		if err := monika.Validate(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%s is invalid:\n%s\n", configPath, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid\n", configPath)
+	case "probe":
+		configPath, positional := parseFlags(command, args)
+		probeID := requireArgument(command, positional, "probe-id|name")
+		if err := monika.ProbeOnce(configPath, probeID, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "notify-test":
+		configPath, positional := parseFlags(command, args)
+		notificationID := requireArgument(command, positional, "notification-id")
+		if err := monika.NotifyTest(configPath, notificationID); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send test notification: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Test notification sent to %s\n", notificationID)
+	case "replay":
+		configPath, _ := parseFlags(command, args)
+		if err := monika.ReplayDeadLetters(configPath, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "version":
+		fmt.Println(version)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+// parseFlags parses the flags of a command and returns the config path and the positional arguments
+func parseFlags(command string, args []string) (string, []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+	}
+
+	// Short and long flags definitions
+	configShortFlag := flags.String("c", "", "Path to config file")
+	configLongFlag := flags.String("config", "", "Path to config file")
+
+	// Allow flags after the positional arguments, e.g. "monika probe my-probe -c monika.yml"
+	positional := make([]string, 0)
+	for {
+		flags.Parse(args)
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	// If config flag is set, use it
+	// If not, use default config file
+	switch {
+	case *configShortFlag != "":
+		return *configShortFlag, positional
+	case *configLongFlag != "":
+		return *configLongFlag, positional
+	default:
+		return "monika.yml", positional
+	}
+}
+
+// requireArgument returns the single positional argument of a command or exits with the usage
+func requireArgument(command string, positional []string, name string) string {
+	if len(positional) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: monika %s <%s> [flags]\n", command, name)
+		os.Exit(2)
+	}
+	return positional[0]
+}
+
+func printBanner() {
 	fmt.Println(" __  __          _ _        ")
 	fmt.Println("|  \\/  |___ _ _ (_) |____ _ ")
 	fmt.Println("| |\\/| / _ \\ ' \\| | / / _` |")
-	fmt.Printf("%s %s\n", "|_|  |_\\___/_||_|_|_\\_\\__,_|", "v0.0.1")
-
-	monika.Init()
-	select {}
+	fmt.Printf("%s %s\n", "|_|  |_\\___/_||_|_|_\\_\\__,_|", version)
 }
@@ -6,26 +6,32 @@ toolchain go1.23.8
 
 require (
 	github.com/goccy/go-yaml v1.17.1 // direct
-	github.com/google/uuid v1.6.0 // direct
 	github.com/rs/zerolog v1.34.0 // direct
 )
 
-require github.com/prometheus-community/pro-bing v0.6.1
+require (
+	github.com/expr-lang/expr v1.17.2
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/go-co-op/gocron/v2 v2.16.1
+	github.com/go-sql-driver/mysql v1.9.3
+	github.com/lib/pq v1.12.3
+	github.com/miekg/dns v1.1.63
+	github.com/prometheus-community/pro-bing v0.6.1
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/xhit/go-simple-mail/v2 v2.16.0
+)
 
 require (
+	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/expr-lang/expr v1.17.2 // indirect
-	github.com/fsnotify/fsnotify v1.9.0 // indirect
-	github.com/go-co-op/gocron/v2 v2.16.1 // indirect
 	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/jonboulle/clockwork v0.5.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/toorop/go-dkim v0.0.0-20201103131630-e1cd1a0a5208 // indirect
-	github.com/xhit/go-simple-mail/v2 v2.16.0 // indirect
 	golang.org/x/net v0.38.0 // indirect
 	golang.org/x/sync v0.12.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
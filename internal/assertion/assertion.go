@@ -25,3 +25,20 @@ func Evaluate(expression string, data map[string]interface{}) bool {
 
 	return boolResult
 }
+
+func Compute(expression string, data map[string]interface{}) (interface{}, error) {
+	// Parse the expression
+	program, err := expr.Compile(expression, expr.Env(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluate the expression
+	return expr.Run(program, data)
+}
+
+func Validate(expression string, data map[string]interface{}) error {
+	// Only compile the expression, it is not evaluated
+	_, err := expr.Compile(expression, expr.Env(data), expr.AsBool())
+	return err
+}
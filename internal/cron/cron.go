@@ -1,8 +1,10 @@
 package cron
 
 import (
+	"fmt"
 	"hyperjumptech/monika/internal/loader"
 	"hyperjumptech/monika/internal/logger"
+	"hyperjumptech/monika/internal/notification"
 	"time"
 
 	ssl "hyperjumptech/monika/internal/cron/jobs"
@@ -10,19 +12,29 @@ import (
 	"github.com/go-co-op/gocron/v2"
 )
 
-func StartCron(cron gocron.Scheduler) {
+func StartCron(cron gocron.Scheduler, conf *loader.Config, checker *ssl.Checker, channels notification.Channels) {
 	logger := logger.GetLogger()
 
 	// Start cron
 	cron.Start()
 
-	// Job to check for SSL
+	// Run the SSL checks on their schedule, or else at their interval
+	definition := gocron.DurationJob(time.Duration(conf.SSL.Interval) * time.Second)
+	schedule := fmt.Sprintf("every %d seconds", conf.SSL.Interval)
+	if conf.SSL.Schedule != "" {
+		definition = gocron.CronJob(conf.SSL.Schedule, false)
+		schedule = fmt.Sprintf("on schedule %q", conf.SSL.Schedule)
+	}
+
+	// Job to check for SSL, starting right away
 	_, err := cron.NewJob(
-		gocron.DurationJob(time.Duration(10)*time.Second),
-		gocron.NewTask(ssl.Check, loader.GetConfig()),
+		definition,
+		gocron.NewTask(checker.Check, conf, channels),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
 	)
 	if err != nil {
 		logger.Warn().Err(err).Str("context", "cron").Str("type", "ssl").Msg("Failed to run SSL checker job")
+		return
 	}
-	logger.Info().Str("context", "cron").Str("type", "ssl").Msg("SSL checker job started at 10 seconds interval")
+	logger.Info().Str("context", "cron").Str("type", "ssl").Msgf("SSL checker job started, running %s", schedule)
 }
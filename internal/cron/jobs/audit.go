@@ -0,0 +1,133 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"hyperjumptech/monika/internal/loader"
+	"hyperjumptech/monika/internal/logger"
+	"hyperjumptech/monika/internal/notification"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// auditVersions are the TLS versions tried by the audit, oldest first
+var auditVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// Audit enumerates the TLS versions and cipher suites accepted by the server
+// and returns the ones the policy does not allow. The cipher suites of TLS 1.3
+// cannot be configured and are all considered strong.
+func Audit(hostname string, serverAddr string, policy loader.ConfigSSLAudit) ([]Issue, error) {
+	logger := logger.GetLogger()
+
+	// Every cipher suite is offered, so that a version is not missed when the
+	// server only accepts suites left out of the defaults, e.g. 3DES
+	suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	suiteIDs := make([]uint16, 0, len(suites))
+	for _, suite := range suites {
+		suiteIDs = append(suiteIDs, suite.ID)
+	}
+
+	versions := make([]uint16, 0)
+	for _, version := range auditVersions {
+		if handshake(hostname, serverAddr, version, version, suiteIDs) == nil {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no TLS version accepted by %s", serverAddr)
+	}
+
+	issues := make([]Issue, 0)
+	minVersion := loader.TLSVersions[policy.MinVersion]
+	outdated := make([]string, 0)
+	for _, version := range versions {
+		if version < minVersion {
+			outdated = append(outdated, tls.VersionName(version))
+		}
+	}
+	if len(outdated) > 0 {
+		issues = append(issues, Issue{
+			Type:    notification.EventSSLWeakProtocol,
+			Message: fmt.Sprintf("Server %s accepts %s, older than %s", serverAddr, strings.Join(outdated, ", "), tls.VersionName(minVersion)),
+		})
+	}
+
+	// The cipher suites are enumerated over the accepted versions up to TLS 1.2
+	lowest, highest := versions[0], versions[len(versions)-1]
+	if highest > tls.VersionTLS12 {
+		highest = tls.VersionTLS12
+	}
+	if lowest > highest {
+		return issues, nil
+	}
+
+	weakCiphers := make(map[string]bool)
+	for _, cipher := range policy.WeakCiphers {
+		weakCiphers[cipher] = true
+	}
+	accepted := make([]string, 0)
+	weak := make([]string, 0)
+	for _, suite := range suites {
+		if !supportsVersions(suite, lowest, highest) {
+			continue
+		}
+		if handshake(hostname, serverAddr, lowest, highest, []uint16{suite.ID}) != nil {
+			continue
+		}
+		accepted = append(accepted, suite.Name)
+		if weakCiphers[suite.Name] {
+			weak = append(weak, suite.Name)
+		}
+	}
+	logger.Info().Str("context", "cron").Str("type", "ssl").Msgf("Server %s accepts %d cipher suites: %s", serverAddr, len(accepted), strings.Join(accepted, ", "))
+
+	if len(weak) > 0 {
+		issues = append(issues, Issue{
+			Type:    notification.EventSSLWeakCipher,
+			Message: fmt.Sprintf("Server %s accepts weak cipher suites: %s", serverAddr, strings.Join(weak, ", ")),
+		})
+	}
+
+	return issues, nil
+}
+
+// supportsVersions reports whether the cipher suite can be negotiated between the versions
+func supportsVersions(suite *tls.CipherSuite, lowest uint16, highest uint16) bool {
+	for _, version := range suite.SupportedVersions {
+		if version >= lowest && version <= highest {
+			return true
+		}
+	}
+	return false
+}
+
+// handshake connects to the server with the TLS versions and cipher suites,
+// it fails if the server does not accept any of them
+func handshake(hostname string, serverAddr string, minVersion uint16, maxVersion uint16, cipherSuites []uint16) error {
+	// Older versions and weak cipher suites are offered on purpose, and the
+	// certificate is verified separately
+	tlsConfig := &tls.Config{
+		ServerName:         hostname,
+		MinVersion:         minVersion,
+		MaxVersion:         maxVersion,
+		CipherSuites:       cipherSuites,
+		InsecureSkipVerify: true,
+	}
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", serverAddr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if cipherSuites != nil && !slices.Contains(cipherSuites, conn.ConnectionState().CipherSuite) {
+		return errors.New("cipher suite not negotiated")
+	}
+	return nil
+}
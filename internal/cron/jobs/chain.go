@@ -0,0 +1,167 @@
+package ssl
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"hyperjumptech/monika/internal/notification"
+	"os"
+	"time"
+)
+
+// Minimum key sizes in bits, below which a key is reported as short
+const (
+	minRSAKeySize   = 2048
+	minECDSAKeySize = 256
+)
+
+// weakSignatureAlgorithms are the signature algorithms considered broken
+var weakSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// Issue is a problem of a certificate chain, reported as its own event type
+type Issue struct {
+	Type    notification.EventType
+	Message string
+}
+
+// LoadRoots returns the system certificate authorities, with the ones of the
+// PEM bundle if it is set
+func LoadRoots(ca string) (*x509.CertPool, error) {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if ca == "" {
+		return roots, nil
+	}
+
+	contents, err := os.ReadFile(ca)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	if !roots.AppendCertsFromPEM(contents) {
+		return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", ca)
+	}
+	return roots, nil
+}
+
+// VerifyChain checks the certificate chain presented for the hostname, the
+// leaf first, against the roots and returns its problems. Expired
+// certificates are left to the expiry thresholds.
+func VerifyChain(hostname string, certs []*x509.Certificate, roots *x509.CertPool, now time.Time) []Issue {
+	if len(certs) == 0 {
+		return nil
+	}
+	leaf := certs[0]
+	issues := make([]Issue, 0)
+
+	if err := leaf.VerifyHostname(hostname); err != nil {
+		issues = append(issues, Issue{
+			Type:    notification.EventSSLHostnameMismatch,
+			Message: fmt.Sprintf("SSL certificate for %s does not match the hostname: %s", hostname, err),
+		})
+	}
+
+	// A CA certificate expiring first cuts the chain short before the leaf expires
+	for _, cert := range certs[1:] {
+		if !isSelfSigned(cert) && cert.NotAfter.Before(leaf.NotAfter) {
+			issues = append(issues, Issue{
+				Type:    notification.EventSSLIntermediateExpiring,
+				Message: fmt.Sprintf("SSL intermediate certificate %q of %s expires at %s, before the certificate at %s", cert.Subject.CommonName, hostname, cert.NotAfter, leaf.NotAfter),
+			})
+		}
+	}
+
+	// The signatures of the roots are not relied upon, so only the others are checked
+	weakSignature := false
+	for _, cert := range certs {
+		if weakSignatureAlgorithms[cert.SignatureAlgorithm] && (cert == leaf || !isSelfSigned(cert)) {
+			weakSignature = true
+			issues = append(issues, Issue{
+				Type:    notification.EventSSLWeakSignature,
+				Message: fmt.Sprintf("SSL certificate %q of %s is signed with the weak %s algorithm", cert.Subject.CommonName, hostname, cert.SignatureAlgorithm),
+			})
+		}
+	}
+
+	for _, cert := range certs {
+		if key, short := shortKey(cert); short {
+			issues = append(issues, Issue{
+				Type:    notification.EventSSLShortKey,
+				Message: fmt.Sprintf("SSL certificate %q of %s has a weak %s key", cert.Subject.CommonName, hostname, key),
+			})
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case err == nil:
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+	case errors.As(err, &unknownAuthorityErr):
+		// Weak signatures are not verified, so their chain is never trusted
+		if weakSignature {
+			break
+		}
+		if len(certs) == 1 && isSelfSigned(leaf) {
+			issues = append(issues, Issue{
+				Type:    notification.EventSSLSelfSigned,
+				Message: fmt.Sprintf("SSL certificate for %s is self-signed", hostname),
+			})
+		} else {
+			issues = append(issues, Issue{
+				Type:    notification.EventSSLUntrustedRoot,
+				Message: fmt.Sprintf("SSL certificate for %s is not signed by a trusted authority: %s", hostname, err),
+			})
+		}
+	default:
+		issues = append(issues, Issue{
+			Type:    notification.EventSSLInvalidChain,
+			Message: fmt.Sprintf("SSL certificate chain of %s is invalid: %s", hostname, err),
+		})
+	}
+
+	return issues
+}
+
+// isSelfSigned reports whether the certificate is issued by its own subject, e.g. a root
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject)
+}
+
+// shortKey describes the public key of the certificate and reports whether it
+// is too short. DSA keys are always reported, DSA is deprecated and has no
+// secure key size usable with TLS.
+func shortKey(cert *x509.Certificate) (string, bool) {
+	if cert.PublicKeyAlgorithm == x509.DSA {
+		return "DSA", true
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("%d bits RSA", key.N.BitLen()), key.N.BitLen() < minRSAKeySize
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("%d bits ECDSA", key.Curve.Params().BitSize), key.Curve.Params().BitSize < minECDSAKeySize
+	default:
+		return "", false
+	}
+}
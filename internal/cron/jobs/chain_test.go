@@ -0,0 +1,225 @@
+package ssl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+// certificate is a generated certificate with its private key
+type certificate struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// certOptions describe a generated certificate
+type certOptions struct {
+	name     string
+	ca       bool
+	dnsNames []string
+	notAfter time.Time
+	// key is the key of the certificate, a P-256 ECDSA key if nil
+	key crypto.Signer
+	// signatureAlgorithm is the algorithm signing the certificate, the default one of the issuer key if not set
+	signatureAlgorithm x509.SignatureAlgorithm
+}
+
+// generate creates a certificate issued by the issuer, or self-signed if the issuer is nil
+func generate(t *testing.T, options certOptions, issuer *certificate) *certificate {
+	t.Helper()
+
+	key := options.key
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: options.name},
+		DNSNames:              options.dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              options.notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  options.ca,
+		SignatureAlgorithm:    options.signatureAlgorithm,
+	}
+	if options.ca {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificate{cert: cert, key: key}
+}
+
+// serve presents the chain, the leaf first, on a local TLS listener and
+// returns its address
+func serve(t *testing.T, chain []*certificate) string {
+	t.Helper()
+
+	tlsCert := tls.Certificate{PrivateKey: chain[0].key, Leaf: chain[0].cert}
+	for _, cert := range chain {
+		tlsCert.Certificate = append(tlsCert.Certificate, cert.cert.Raw)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{tlsCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestVerifyChain(t *testing.T) {
+	now := time.Now()
+	inAYear := now.AddDate(1, 0, 0)
+
+	root := generate(t, certOptions{name: "Test Root", ca: true, notAfter: now.AddDate(10, 0, 0)}, nil)
+	intermediate := generate(t, certOptions{name: "Test Intermediate", ca: true, notAfter: now.AddDate(5, 0, 0)}, root)
+
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaIntermediate := generate(t, certOptions{name: "Test RSA Intermediate", ca: true, notAfter: now.AddDate(5, 0, 0), key: rsaKey}, root)
+
+	tests := []struct {
+		name  string
+		chain func() []*certificate
+		// trusted reports whether the root is trusted
+		trusted bool
+		want    []notification.EventType
+	}{
+		{
+			name: "valid chain",
+			chain: func() []*certificate {
+				leaf := generate(t, certOptions{name: "localhost", dnsNames: []string{"localhost"}, notAfter: inAYear}, intermediate)
+				return []*certificate{leaf, intermediate}
+			},
+			trusted: true,
+			want:    []notification.EventType{},
+		},
+		{
+			name: "hostname mismatch",
+			chain: func() []*certificate {
+				leaf := generate(t, certOptions{name: "example.com", dnsNames: []string{"example.com"}, notAfter: inAYear}, intermediate)
+				return []*certificate{leaf, intermediate}
+			},
+			trusted: true,
+			want:    []notification.EventType{notification.EventSSLHostnameMismatch},
+		},
+		{
+			name: "self-signed certificate",
+			chain: func() []*certificate {
+				leaf := generate(t, certOptions{name: "localhost", dnsNames: []string{"localhost"}, notAfter: inAYear}, nil)
+				return []*certificate{leaf}
+			},
+			trusted: true,
+			want:    []notification.EventType{notification.EventSSLSelfSigned},
+		},
+		{
+			name: "untrusted root",
+			chain: func() []*certificate {
+				leaf := generate(t, certOptions{name: "localhost", dnsNames: []string{"localhost"}, notAfter: inAYear}, intermediate)
+				return []*certificate{leaf, intermediate, root}
+			},
+			trusted: false,
+			want:    []notification.EventType{notification.EventSSLUntrustedRoot},
+		},
+		{
+			name: "intermediate expiring before the certificate",
+			chain: func() []*certificate {
+				expiring := generate(t, certOptions{name: "Test Expiring Intermediate", ca: true, notAfter: now.AddDate(0, 1, 0)}, root)
+				leaf := generate(t, certOptions{name: "localhost", dnsNames: []string{"localhost"}, notAfter: inAYear}, expiring)
+				return []*certificate{leaf, expiring}
+			},
+			trusted: true,
+			want:    []notification.EventType{notification.EventSSLIntermediateExpiring},
+		},
+		{
+			name: "short key",
+			chain: func() []*certificate {
+				leaf := generate(t, certOptions{name: "localhost", dnsNames: []string{"localhost"}, notAfter: inAYear, key: weakKey}, intermediate)
+				return []*certificate{leaf, intermediate}
+			},
+			trusted: true,
+			want:    []notification.EventType{notification.EventSSLShortKey},
+		},
+		{
+			name: "weak signature",
+			chain: func() []*certificate {
+				leaf := generate(t, certOptions{name: "localhost", dnsNames: []string{"localhost"}, notAfter: inAYear, signatureAlgorithm: x509.SHA1WithRSA}, rsaIntermediate)
+				return []*certificate{leaf, rsaIntermediate}
+			},
+			trusted: true,
+			want:    []notification.EventType{notification.EventSSLWeakSignature},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr := serve(t, test.chain())
+			certs, err := fetchCertificates("localhost", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			roots := x509.NewCertPool()
+			if test.trusted {
+				roots.AddCert(root.cert)
+			}
+
+			got := make([]notification.EventType, 0)
+			for _, issue := range VerifyChain("localhost", certs, roots, now) {
+				got = append(got, issue.Type)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got issues %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -2,17 +2,51 @@
 package ssl
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"hyperjumptech/monika/internal/loader"
 	"hyperjumptech/monika/internal/logger"
 	"hyperjumptech/monika/internal/notification"
 	"net"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
-func Check(conf *loader.Config) {
+// Checker checks the SSL certificates of the HTTPS probes and notifies once
+// per expiry threshold crossed by each certificate, and once per chain issue
+type Checker struct {
+	mu sync.Mutex
+	// notified is the expiry notified for each probe and address
+	notified map[string]expiryState
+	// issues are the chain issues notified for each probe and address
+	issues map[string]map[notification.EventType]bool
+}
+
+// expiryState is the expiry notified for a certificate
+type expiryState struct {
+	// serial and notAfter identify the notified certificate, a renewed
+	// certificate starts over from its first threshold
+	serial   string
+	notAfter time.Time
+	// threshold is the last threshold notified, 0 once the certificate has expired
+	threshold int
+}
+
+// NewChecker creates an SSL checker without any notified threshold or issue
+func NewChecker() *Checker {
+	return &Checker{
+		notified: make(map[string]expiryState),
+		issues:   make(map[string]map[notification.EventType]bool),
+	}
+}
+
+// Check checks the SSL certificate of the HTTPS requests of every probe
+func (c *Checker) Check(conf *loader.Config, channels notification.Channels) {
 	logger := logger.GetLogger()
 
 	// Check if config is loaded
@@ -23,15 +57,16 @@ func Check(conf *loader.Config) {
 	}
 
 	// Filter probes based on type
-	// We only want to check SSL of HTTPS probes
+	// We only want to check SSL of HTTPS probes that did not opt out
 	HTTProbes := make([]loader.ConfigProbe, 0)
+	checkedProbes := make(map[string]bool)
 	for _, probe := range conf.Probes {
-		if probe.Ping.Uri == "" {
+		if probe.Type() == "http" && !probe.SSL.Disabled {
 			HTTProbes = append(HTTProbes, probe)
+			checkedProbes[probe.ID] = true
 		}
-
-		continue
 	}
+	c.forget(checkedProbes)
 
 	// If no HTTP probes found, skip the job
 	if len(HTTProbes) == 0 {
@@ -41,6 +76,22 @@ func Check(conf *loader.Config) {
 
 	// Check SSL of all HTTP probes
 	for _, probe := range HTTProbes {
+		thresholds := conf.SSL.Thresholds
+		if len(probe.SSL.Thresholds) > 0 {
+			thresholds = probe.SSL.Thresholds
+		}
+		ca := conf.SSL.CA
+		if probe.SSL.CA != "" {
+			ca = probe.SSL.CA
+		}
+		roots, err := LoadRoots(ca)
+		if err != nil {
+			logger.Warn().Err(err).Str("context", "cron").Str("type", "ssl").Msgf("Failed to load the CA bundle of probe %s. Skipping...", probe.Name)
+			continue
+		}
+
+		// Requests of a probe often share the same server, check it once
+		checked := make(map[string]bool)
 		for _, request := range probe.Requests {
 			parsedURL, err := url.Parse(request.URL)
 			if err != nil {
@@ -65,69 +116,211 @@ func Check(conf *loader.Config) {
 				port = "443"
 			}
 
-			serverAddr := fmt.Sprintf("%s:%s", hostname, port)
-			logger.Info().Str("context", "cron").Str("type", "ssl").Msgf("Checking SSL of probe %s at %s", probe.Name, serverAddr)
-
-			// Set up proper TLS config
-			tlsConfig := &tls.Config{
-				ServerName: hostname,
-				MinVersion: tls.VersionTLS12,
-			}
-
-			// Connect with timeout
-			dialer := &net.Dialer{
-				Timeout: 10 * time.Second,
-			}
-
-			conn, err := tls.DialWithDialer(dialer, "tcp", serverAddr, tlsConfig)
-			if err != nil {
-				logger.Warn().Err(err).Str("context", "cron").Str("type", "ssl").Msgf("Failed to establish SSL connection to %s", serverAddr)
+			serverAddr := net.JoinHostPort(hostname, port)
+			if checked[serverAddr] {
 				continue
 			}
-			defer conn.Close()
+			checked[serverAddr] = true
 
-			// Certificate validation
-			certs := conn.ConnectionState().PeerCertificates
-			if len(certs) == 0 {
-				logger.Warn().Str("context", "cron").Str("type", "ssl").Msgf("No certificates found for %s", serverAddr)
+			logger.Info().Str("context", "cron").Str("type", "ssl").Msgf("Checking SSL of probe %s at %s", probe.Name, serverAddr)
+			certs, err := fetchCertificates(hostname, serverAddr)
+			if err != nil {
+				logger.Warn().Err(err).Str("context", "cron").Str("type", "ssl").Msgf("Failed to get the SSL certificate of %s", serverAddr)
 				continue
 			}
 
-			cert := certs[0]
-
-			// Check expiry
+			key := probe.ID + "|" + serverAddr
 			now := time.Now()
-			expiresIn := cert.NotAfter.Sub(now)
-
-			if now.After(cert.NotAfter) {
-				logger.Warn().
-					Str("context", "cron").
-					Str("type", "ssl").
-					Msgf("SSL certificate for %s is expired, expired at %s", hostname, cert.NotAfter)
-
-				// Send notification
-				for _, n := range conf.Notifications {
-					message := fmt.Sprintf("SSL certificate for %s is expired, expired at %s", hostname, cert.NotAfter)
-					notification.SendNotification(n, message)
+			issues := VerifyChain(hostname, certs, roots, now)
+			if conf.SSL.Audit.Enabled {
+				auditIssues, err := Audit(hostname, serverAddr, conf.SSL.Audit)
+				if err != nil {
+					logger.Warn().Err(err).Str("context", "cron").Str("type", "ssl").Msgf("Failed to audit the TLS configuration of %s", serverAddr)
+					// The audit issues are unknown, so the notified ones are not resolved
+					auditIssues = c.notifiedIssues(key, notification.EventSSLWeakProtocol, notification.EventSSLWeakCipher)
 				}
-			} else if expiresIn == 30*24*time.Hour || expiresIn < 14*24*time.Hour || expiresIn < 7*24*time.Hour {
-				// Warn if certificate expires in equal to 30 days, equal to 14 days or equal to 7 days
-				logger.Warn().
-					Str("context", "cron").
-					Str("type", "ssl").
-					Msgf("SSL certificate for %s expires soon, expired at %s", hostname, cert.NotAfter)
-
-				// Send notification
-				for _, n := range conf.Notifications {
-					message := fmt.Sprintf("SSL certificate for %s expires soon, expired at %s", hostname, cert.NotAfter)
-					notification.SendNotification(n, message)
-				}
-			} else {
-				logger.Info().
-					Str("context", "cron").
-					Str("type", "ssl").
-					Msgf("SSL certificate for %s is valid", hostname)
+				issues = append(issues, auditIssues...)
+			}
+			issues, resolved := c.newIssues(key, issues)
+			message, notify, renewed := c.expiry(key, hostname, certs[0], thresholds, now)
+			if notify {
+				issues = append([]Issue{{Type: notification.EventSSLExpiring, Message: message}}, issues...)
+			}
+			if renewed {
+				resolved = append([]notification.EventType{notification.EventSSLExpiring}, resolved...)
+			}
+
+			// Send notifications
+			for _, issue := range issues {
+				logger.Warn().Str("context", "cron").Str("type", "ssl").Msg(issue.Message)
+				channels.Select(probe.Notifications).Send(context.Background(), notification.Event{
+					Type:       issue.Type,
+					ProbeID:    probe.ID,
+					ProbeName:  probe.Name,
+					RequestURL: request.URL,
+					Timestamp:  now,
+					Message:    issue.Message,
+				})
+			}
+			for _, issueType := range resolved {
+				logger.Info().Str("context", "cron").Str("type", "ssl").Msgf("SSL problem %s of %s is resolved", issueType, serverAddr)
+				channels.Select(probe.Notifications).Send(context.Background(), notification.Event{
+					Type:       notification.EventSSLResolved,
+					ProbeID:    probe.ID,
+					ProbeName:  probe.Name,
+					RequestURL: request.URL,
+					Timestamp:  now,
+					Message:    fmt.Sprintf("The %s problem of %s is resolved", issueType, serverAddr),
+					Issue:      issueType,
+				})
 			}
 		}
 	}
 }
+
+// forget drops what was notified for the probes that are no longer checked,
+// e.g. removed from the configuration, so the state does not grow forever
+func (c *Checker) forget(probeIDs map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The keys are the probe ID and the server address, which has no "|"
+	probeID := func(key string) string {
+		return key[:strings.LastIndex(key, "|")]
+	}
+	for key := range c.notified {
+		if !probeIDs[probeID(key)] {
+			delete(c.notified, key)
+		}
+	}
+	for key := range c.issues {
+		if !probeIDs[probeID(key)] {
+			delete(c.issues, key)
+		}
+	}
+}
+
+// newIssues returns the chain issues not notified yet for the key and the types
+// of the notified ones that are resolved, which are forgotten so that they are
+// notified again if they come back
+func (c *Checker) newIssues(key string, issues []Issue) ([]Issue, []notification.EventType) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	notified := c.issues[key]
+	current := make(map[notification.EventType]bool)
+	newIssues := make([]Issue, 0)
+	for _, issue := range issues {
+		if !notified[issue.Type] && !current[issue.Type] {
+			newIssues = append(newIssues, issue)
+		}
+		current[issue.Type] = true
+	}
+
+	resolved := make([]notification.EventType, 0)
+	for issueType := range notified {
+		if !current[issueType] {
+			resolved = append(resolved, issueType)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i] < resolved[j] })
+
+	if len(current) == 0 {
+		delete(c.issues, key)
+	} else {
+		c.issues[key] = current
+	}
+	return newIssues, resolved
+}
+
+// notifiedIssues returns the issues of the given types already notified for the key
+func (c *Checker) notifiedIssues(key string, types ...notification.EventType) []Issue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	issues := make([]Issue, 0)
+	for _, issueType := range types {
+		if c.issues[key][issueType] {
+			issues = append(issues, Issue{Type: issueType})
+		}
+	}
+	return issues
+}
+
+// expiry describes the expiry of the certificate and reports whether it crossed
+// a threshold, or expired, since the last notification for the key, and whether
+// a notified certificate has been renewed
+func (c *Checker) expiry(key string, hostname string, cert *x509.Certificate, thresholds []int, now time.Time) (string, bool, bool) {
+	logger := logger.GetLogger()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A renewed certificate is notified again once it crosses a threshold
+	state, tracked := c.notified[key]
+	renewed := tracked && (state.serial != cert.SerialNumber.String() || !state.notAfter.Equal(cert.NotAfter))
+	notified := tracked && !renewed
+	current := expiryState{serial: cert.SerialNumber.String(), notAfter: cert.NotAfter}
+
+	if now.After(cert.NotAfter) {
+		message := fmt.Sprintf("SSL certificate for %s is expired, expired at %s", hostname, cert.NotAfter)
+		c.notified[key] = current
+		return message, !notified || state.threshold > 0, false
+	}
+
+	// Find the smallest threshold reached by the certificate
+	daysLeft := int(cert.NotAfter.Sub(now).Hours() / 24)
+	sorted := append([]int(nil), thresholds...)
+	sort.Ints(sorted)
+	crossed := 0
+	for _, threshold := range sorted {
+		if daysLeft <= threshold {
+			crossed = threshold
+			break
+		}
+	}
+
+	// The certificate has not reached any threshold, e.g. it has been renewed
+	if crossed == 0 {
+		delete(c.notified, key)
+		logger.Info().Str("context", "cron").Str("type", "ssl").Msgf("SSL certificate for %s is valid, expires at %s", hostname, cert.NotAfter)
+		return "", false, tracked
+	}
+
+	if notified && state.threshold <= crossed {
+		logger.Info().Str("context", "cron").Str("type", "ssl").Msgf("SSL certificate for %s expires in %d days, already notified", hostname, daysLeft)
+		return "", false, false
+	}
+	current.threshold = crossed
+	c.notified[key] = current
+
+	return fmt.Sprintf("SSL certificate for %s expires in %d days, at %s", hostname, daysLeft, cert.NotAfter), true, false
+}
+
+// fetchCertificates returns the certificate chain presented by the server, the leaf first
+func fetchCertificates(hostname string, serverAddr string) ([]*x509.Certificate, error) {
+	// The chain is read without being verified, so that expired or otherwise
+	// invalid certificates are reported as well, from servers of any version
+	tlsConfig := &tls.Config{
+		ServerName:         hostname,
+		MinVersion:         tls.VersionTLS10,
+		InsecureSkipVerify: true,
+	}
+
+	// Connect with timeout
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", serverAddr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found for %s", serverAddr)
+	}
+	return certs, nil
+}
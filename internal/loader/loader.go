@@ -2,48 +2,51 @@ package loader
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
 	"io"
 	"strconv"
 	"strings"
 
 	"github.com/goccy/go-yaml"
-	"github.com/google/uuid"
+	"github.com/goccy/go-yaml/parser"
 )
 
-// ConfigNotificationData holds the configuration for notifications
-type ConfigNotificationData struct {
-	// Discord notification
-	URL string `yaml:"url,omitempty"`
-
-	// SMTP notification
-	Recipients []string `yaml:"recipients,omitempty"`
-	Host       string   `yaml:"hostname,omitempty"`
-	Port       int      `yaml:"port,omitempty"`
-	Username   string   `yaml:"username,omitempty"`
-	Password   string   `yaml:"password,omitempty"`
-}
+// ConfigNotification holds the configuration of a notification channel.
+// Data is decoded into the typed configuration of the channel by its notifier.
+type ConfigNotification struct {
+	ID   string                 `yaml:"id"`
+	Type string                 `yaml:"type"`
+	Data map[string]interface{} `yaml:"data"`
 
-// GetDiscordConfig returns the Discord configuration
-func (d *ConfigNotificationData) GetDiscordConfig() (string, bool) {
-	if d.URL != "" {
-		return d.URL, true
-	}
-	return "", false
+	// Timeout is the timeout in milliseconds of each attempt to send a notification,
+	// it defaults to the timeout of the delivery configuration
+	Timeout int `yaml:"timeout"`
+
+	// Templates customize the messages of the notification by event type
+	Templates map[string]ConfigTemplate `yaml:"templates"`
 }
 
-// GetSMTPConfig returns the SMTP configuration if all required fields are present
-func (d *ConfigNotificationData) GetSMTPConfig() (host string, port int, username, password string, recipients []string, ok bool) {
-	if d.Host != "" && d.Port > 0 && len(d.Recipients) > 0 {
-		return d.Host, d.Port, d.Username, d.Password, d.Recipients, true
-	}
-	return "", 0, "", "", nil, false
+// ConfigTemplate holds the Go templates of the messages of an event type
+type ConfigTemplate struct {
+	Subject string `yaml:"subject"`
+	Body    string `yaml:"body"`
 }
 
-type ConfigNotification struct {
-	ID   string                 `yaml:"id"`
-	Type string                 `yaml:"type"`
-	Data ConfigNotificationData `yaml:"data"`
+// ConfigDelivery holds how the notifications are delivered and retried
+type ConfigDelivery struct {
+	// Attempts is the maximum number of attempts to send a notification
+	Attempts int `yaml:"attempts"`
+	// Backoff is the delay in milliseconds before the first retry, doubled on each retry
+	Backoff int `yaml:"backoff"`
+	// MaxBackoff is the longest delay in milliseconds between two attempts
+	MaxBackoff int `yaml:"max_backoff"`
+	// Timeout is the timeout in milliseconds of each attempt to send a notification
+	Timeout int `yaml:"timeout"`
+	// DeadLetters is the file recording the notifications that could not be delivered
+	DeadLetters string `yaml:"dead_letters"`
 }
 
 type ConfigProbePing struct {
@@ -51,31 +54,163 @@ type ConfigProbePing struct {
 	Alerts []ConfigProbeRequestAlert `yaml:"alerts"`
 }
 
+// ConfigProbeSocket holds the TCP service checked by a socket probe
+type ConfigProbeSocket struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// Data is sent once connected, e.g. "PING\r\n"
+	Data string `yaml:"data"`
+	// Expected is the data the response must contain
+	Expected string `yaml:"expected"`
+	// Timeout is the timeout in milliseconds of the connection and the response
+	Timeout int                       `yaml:"timeout"`
+	Alerts  []ConfigProbeRequestAlert `yaml:"alerts"`
+}
+
+// ConfigProbeDNS holds the record queried by a DNS probe
+type ConfigProbeDNS struct {
+	Name string `yaml:"name"`
+	// Type is the record type, e.g. "A" or "MX"
+	Type string `yaml:"type"`
+	// Server is the resolver address, the system resolver is used if it is not set
+	Server string `yaml:"server"`
+	// Protocol is "udp" or "tcp"
+	Protocol string `yaml:"protocol"`
+	// Timeout is the timeout in milliseconds of the query
+	Timeout int                       `yaml:"timeout"`
+	Alerts  []ConfigProbeRequestAlert `yaml:"alerts"`
+}
+
+// ConfigProbeDatabase holds the database checked by a Postgres or MySQL probe
+type ConfigProbeDatabase struct {
+	DSN string `yaml:"dsn"`
+	// Query is run once connected, e.g. "SELECT count(*) AS jobs FROM jobs"
+	Query string `yaml:"query"`
+	// Timeout is the timeout in milliseconds of the connection and the query
+	Timeout int                       `yaml:"timeout"`
+	Alerts  []ConfigProbeRequestAlert `yaml:"alerts"`
+}
+
 type ConfigProbeRequestAlert struct {
 	Query   string `yaml:"query"`
 	Message string `yaml:"message"`
+
+	// Assertion is an alias of Query, used by the Monika configuration format
+	Assertion string `yaml:"assertion,omitempty"`
+
+	// Notifications are the IDs of the notifications receiving the incidents of
+	// this alert, instead of the notifications of the probe
+	Notifications []string `yaml:"notifications,omitempty"`
 }
 
 type ConfigProbeRequest struct {
 	Timeout           int16                     `yaml:"timeout"`
 	Method            string                    `yaml:"method"`
 	URL               string                    `yaml:"url"`
+	Headers           map[string]string         `yaml:"headers"`
+	Body              interface{}               `yaml:"body"`
+	AllowUnauthorized bool                      `yaml:"allowUnauthorized"`
 	RecoveryThreshold int                       `yaml:"recovery_threshold"`
 	IncidentThreshold int                       `yaml:"incident_threshold"`
 	Alerts            []ConfigProbeRequestAlert `yaml:"alerts"`
+
+	// RecoveryThresholdAlias and IncidentThresholdAlias are aliases of the
+	// thresholds, used by the Monika configuration format
+	RecoveryThresholdAlias int `yaml:"recoveryThreshold,omitempty"`
+	IncidentThresholdAlias int `yaml:"incidentThreshold,omitempty"`
+
+	// SaveBody is accepted for compatibility with the Monika configuration format,
+	// Monika GO does not store responses
+	SaveBody bool `yaml:"saveBody,omitempty"`
 }
 
 type ConfigProbe struct {
-	ID       string
-	Name     string `yaml:"name"`
-	Interval int8   `yaml:"interval"`
-	Requests []ConfigProbeRequest
-	Ping     ConfigProbePing
+	ID          string
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Interval    int8   `yaml:"interval"`
+	Requests    []ConfigProbeRequest
+	Ping        ConfigProbePing
+	Socket      ConfigProbeSocket         `yaml:"socket"`
+	DNS         ConfigProbeDNS            `yaml:"dns"`
+	Postgres    ConfigProbeDatabase       `yaml:"postgres"`
+	MySQL       ConfigProbeDatabase       `yaml:"mysql"`
+	Alerts      []ConfigProbeRequestAlert `yaml:"alerts"`
+
+	// Notifications are the IDs of the notifications receiving the messages of
+	// this probe, all notifications receive them if empty
+	Notifications []string `yaml:"notifications"`
+
+	SSL ConfigProbeSSL `yaml:"ssl"`
+}
+
+// ConfigProbeSSL holds the SSL certificate checks of the HTTPS requests of a probe
+type ConfigProbeSSL struct {
+	// Disabled turns off the SSL certificate checks of the probe
+	Disabled bool `yaml:"disabled"`
+	// Thresholds override the expiry thresholds in days of the SSL configuration
+	Thresholds []int `yaml:"thresholds"`
+	// CA overrides the CA bundle of the SSL configuration
+	CA string `yaml:"ca"`
+}
+
+// ConfigSSL holds when the SSL certificates of the HTTPS probes are checked
+type ConfigSSL struct {
+	// Thresholds are the numbers of days before the expiry of a certificate
+	// at which a notification is sent, once per threshold
+	Thresholds []int `yaml:"thresholds"`
+	// Interval is the interval in seconds between two checks
+	Interval int `yaml:"interval"`
+	// Schedule is a cron expression of the checks, it takes precedence over the interval
+	Schedule string `yaml:"schedule"`
+	// CA is a PEM file of the certificate authorities trusted in addition to the system ones
+	CA string `yaml:"ca"`
+	// Audit checks the TLS versions and cipher suites accepted by the servers
+	Audit ConfigSSLAudit `yaml:"audit"`
+}
+
+// ConfigSSLAudit holds the policy of the TLS configuration of the HTTPS servers
+type ConfigSSLAudit struct {
+	Enabled bool `yaml:"enabled"`
+	// MinVersion is the oldest TLS version a server may accept, e.g. "1.2"
+	MinVersion string `yaml:"min_version"`
+	// WeakCiphers are the names of the cipher suites a server may not accept
+	WeakCiphers []string `yaml:"weak_ciphers"`
+}
+
+// TLSVersions maps the TLS versions of the audit policy to their protocol versions
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Type returns the type of the probe, which selects the prober that runs it
+func (p ConfigProbe) Type() string {
+	if p.Ping.Uri != "" {
+		return "ping"
+	}
+	if p.Socket.Host != "" {
+		return "socket"
+	}
+	if p.DNS.Name != "" {
+		return "dns"
+	}
+	if p.Postgres.DSN != "" {
+		return "postgres"
+	}
+	if p.MySQL.DSN != "" {
+		return "mysql"
+	}
+	return "http"
 }
 
 type Config struct {
 	Probes        []ConfigProbe        `yaml:"probes"`
 	Notifications []ConfigNotification `yaml:"notifications"`
+	Delivery      ConfigDelivery       `yaml:"delivery"`
+	SSL           ConfigSSL            `yaml:"ssl"`
 }
 
 var loadedConfig *Config
@@ -97,11 +232,20 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 	// Convert contents to a single string
 	config := strings.Join(contents, "\n")
 
-	// Parse the Monika configuration file
+	// Parse the Monika configuration file, rejecting unknown keys
 	var configYAML Config
-	err := yaml.Unmarshal([]byte(config), &configYAML)
+	err := yaml.UnmarshalWithOptions([]byte(config), &configYAML, yaml.Strict())
 	if err != nil {
-		return nil, err
+		return nil, decodeError(err)
+	}
+
+	// Validate the configuration, the syntax tree is used to locate the errors
+	file, err := parser.ParseBytes([]byte(config), 0)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	if errs := validate(&configYAML, file); len(errs) > 0 {
+		return nil, errs
 	}
 
 	// Create a parsed configuration
@@ -110,6 +254,9 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 		Notifications: make([]ConfigNotification, 0),
 	}
 
+	// IDs derived for the probes without one, to tell apart the probes sharing a name or target
+	derivedIDs := make(map[string]bool)
+
 	// Assign probes and probe requests
 	for index, probe := range configYAML.Probes {
 		// If probe ID is not set, generate a new one
@@ -118,9 +265,15 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 		var probeRequests []ConfigProbeRequest
 		var probePing ConfigProbePing
 
-		// If ID is not set, generate a new one
+		// If ID is not set, derive one that is kept across reloads
 		if probe.ID == "" {
-			probeID = uuid.NewString()
+			probeID = fallbackID(probe, "")
+			if derivedIDs[probeID] {
+				// The position only sets apart probes with the same name or target,
+				// so adding or moving the other probes keeps their IDs
+				probeID = fallbackID(probe, strconv.Itoa(index))
+			}
+			derivedIDs[probeID] = true
 		} else {
 			probeID = probe.ID
 		}
@@ -140,11 +293,15 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 		}
 
 		probeStruct := ConfigProbe{
-			ID:       probeID,
-			Name:     probeName,
-			Interval: probeInterval,
-			Requests: make([]ConfigProbeRequest, 0),
-			Ping:     ConfigProbePing{},
+			ID:            probeID,
+			Name:          probeName,
+			Description:   probe.Description,
+			Interval:      probeInterval,
+			Requests:      make([]ConfigProbeRequest, 0),
+			Ping:          ConfigProbePing{},
+			Alerts:        normalizeAlerts(probe.Alerts),
+			Notifications: probe.Notifications,
+			SSL:           probe.SSL,
 		}
 
 		if probe.Requests == nil {
@@ -160,16 +317,85 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 		}
 
 		if probe.Ping.Uri != "" {
-			// Handle socket mapping
+			// Handle ping mapping
 			probeStruct.Ping = ConfigProbePing{
-				Uri: probePing.Uri,
+				Uri:    probePing.Uri,
+				Alerts: append(normalizeAlerts(probePing.Alerts), probeStruct.Alerts...),
+			}
+
+			// If no alerts are set, alert when the ping takes more than 2 seconds
+			if len(probeStruct.Ping.Alerts) == 0 {
+				probeStruct.Ping.Alerts = []ConfigProbeRequestAlert{
+					{
+						Query:   "response.time > 2000",
+						Message: "Ping response time is greater than 2 seconds",
+					},
+				}
 			}
 
+			configStruct.Probes = append(configStruct.Probes, probeStruct)
+		} else if probe.Socket.Host != "" {
+			// Handle socket mapping
+			probeStruct.Socket = probe.Socket
+			probeStruct.Socket.Alerts = append(normalizeAlerts(probe.Socket.Alerts), probeStruct.Alerts...)
+
+			// If timeout is not set, set it to 10 seconds
+			if probeStruct.Socket.Timeout == 0 {
+				probeStruct.Socket.Timeout = 10_000 // Default timeout, 10 seconds
+			}
+
+			configStruct.Probes = append(configStruct.Probes, probeStruct)
+		} else if probe.DNS.Name != "" {
+			// Handle DNS mapping
+			probeStruct.DNS = probe.DNS
+			probeStruct.DNS.Type = strings.ToUpper(probe.DNS.Type)
+			probeStruct.DNS.Protocol = strings.ToLower(probe.DNS.Protocol)
+			probeStruct.DNS.Alerts = append(normalizeAlerts(probe.DNS.Alerts), probeStruct.Alerts...)
+
+			// If type is not set, query the A record
+			if probeStruct.DNS.Type == "" {
+				probeStruct.DNS.Type = "A"
+			}
+
+			// If protocol is not set, query over UDP
+			if probeStruct.DNS.Protocol == "" {
+				probeStruct.DNS.Protocol = "udp"
+			}
+
+			// If timeout is not set, set it to 10 seconds
+			if probeStruct.DNS.Timeout == 0 {
+				probeStruct.DNS.Timeout = 10_000 // Default timeout, 10 seconds
+			}
+
+			// If no alerts are set, alert when the record cannot be resolved,
+			// query errors fail the probe on their own
+			if len(probeStruct.DNS.Alerts) == 0 {
+				probeStruct.DNS.Alerts = []ConfigProbeRequestAlert{
+					{
+						Query:   `response.error == "" && response.rcode != "NOERROR"`,
+						Message: "DNS query did not succeed",
+					},
+					{
+						Query:   `response.error == "" && len(response.answers) == 0`,
+						Message: "DNS query returned no records",
+					},
+				}
+			}
+
+			configStruct.Probes = append(configStruct.Probes, probeStruct)
+		} else if probe.Postgres.DSN != "" {
+			// Handle Postgres mapping
+			probeStruct.Postgres = mapDatabase(probe.Postgres, probeStruct.Alerts)
+			configStruct.Probes = append(configStruct.Probes, probeStruct)
+		} else if probe.MySQL.DSN != "" {
+			// Handle MySQL mapping
+			probeStruct.MySQL = mapDatabase(probe.MySQL, probeStruct.Alerts)
 			configStruct.Probes = append(configStruct.Probes, probeStruct)
 		} else {
 			// Handle request mapping
 			for _, request := range probeRequests {
 				var requestURL, requestMethod string
+				var requestHeaders map[string]string
 				var requestTimeout int16
 				var requestRecoveryThreshold, requestIncidentThreshold int
 				var requestAlert []ConfigProbeRequestAlert
@@ -189,6 +415,13 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 					requestMethod = request.Method
 				}
 
+				// If headers are not set, use an empty map
+				if request.Headers == nil {
+					requestHeaders = make(map[string]string)
+				} else {
+					requestHeaders = request.Headers
+				}
+
 				// If timeout is not set, set it to 10 seconds
 				if request.Timeout == 0 {
 					requestTimeout = 10_000 // Default timeout, 10 seconds
@@ -196,6 +429,14 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 					requestTimeout = request.Timeout
 				}
 
+				// Resolve the aliases of the thresholds
+				if request.RecoveryThreshold == 0 {
+					request.RecoveryThreshold = request.RecoveryThresholdAlias
+				}
+				if request.IncidentThreshold == 0 {
+					request.IncidentThreshold = request.IncidentThresholdAlias
+				}
+
 				// If recovery threshold is not set, set it to 5 times
 				if request.RecoveryThreshold == 0 {
 					requestRecoveryThreshold = 5 // Default recovery threshold, 5 times
@@ -210,7 +451,9 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 					requestIncidentThreshold = request.IncidentThreshold
 				}
 
-				if len(request.Alerts) == 0 {
+				// Probe-level alerts are evaluated against every request in the probe
+				requestAlert = append(normalizeAlerts(request.Alerts), probeStruct.Alerts...)
+				if len(requestAlert) == 0 {
 					requestAlert = []ConfigProbeRequestAlert{
 						{
 							Query:   "response.status < 200 || response.status >= 300",
@@ -221,8 +464,6 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 							Message: "Response time is greater than 2 seconds",
 						},
 					}
-				} else {
-					requestAlert = request.Alerts
 				}
 
 				// Assign values
@@ -230,6 +471,9 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 					URL:               requestURL,
 					Timeout:           requestTimeout,
 					Method:            requestMethod,
+					Headers:           requestHeaders,
+					Body:              request.Body,
+					AllowUnauthorized: request.AllowUnauthorized,
 					RecoveryThreshold: requestRecoveryThreshold,
 					IncidentThreshold: requestIncidentThreshold,
 					Alerts:            requestAlert,
@@ -241,12 +485,55 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 		}
 	}
 
+	// Handle SSL checks
+	configStruct.SSL = configYAML.SSL
+	if len(configStruct.SSL.Thresholds) == 0 {
+		configStruct.SSL.Thresholds = []int{30, 14, 7, 1} // Default thresholds, in days
+	}
+	if configStruct.SSL.Interval == 0 {
+		configStruct.SSL.Interval = 3_600 // Default interval, 1 hour
+	}
+	if configStruct.SSL.Audit.MinVersion == "" {
+		configStruct.SSL.Audit.MinVersion = "1.2"
+	}
+	if configStruct.SSL.Audit.WeakCiphers == nil {
+		// Default to the cipher suites with known security issues
+		for _, suite := range tls.InsecureCipherSuites() {
+			configStruct.SSL.Audit.WeakCiphers = append(configStruct.SSL.Audit.WeakCiphers, suite.Name)
+		}
+	}
+
+	// Handle notification delivery
+	configStruct.Delivery = configYAML.Delivery
+	if configStruct.Delivery.Attempts == 0 {
+		configStruct.Delivery.Attempts = 4 // Default attempts, the first one and 3 retries
+	}
+	if configStruct.Delivery.Backoff == 0 {
+		configStruct.Delivery.Backoff = 1_000 // Default backoff, 1 second
+	}
+	if configStruct.Delivery.MaxBackoff == 0 {
+		configStruct.Delivery.MaxBackoff = 60_000 // Default maximum backoff, 1 minute
+	}
+	if configStruct.Delivery.Timeout == 0 {
+		configStruct.Delivery.Timeout = 10_000 // Default timeout, 10 seconds
+	}
+	if configStruct.Delivery.DeadLetters == "" {
+		configStruct.Delivery.DeadLetters = "monika-dead-letters.jsonl"
+	}
+
 	// Handle notifications
 	for _, notification := range configYAML.Notifications {
 		notificationStruct := ConfigNotification{
-			ID:   notification.ID,
-			Type: notification.Type,
-			Data: notification.Data,
+			ID:        notification.ID,
+			Type:      notification.Type,
+			Data:      notification.Data,
+			Timeout:   notification.Timeout,
+			Templates: notification.Templates,
+		}
+
+		// If timeout is not set, use the delivery timeout
+		if notificationStruct.Timeout == 0 {
+			notificationStruct.Timeout = configStruct.Delivery.Timeout
 		}
 		configStruct.Notifications = append(configStruct.Notifications, notificationStruct)
 	}
@@ -257,6 +544,64 @@ func LoadConfig(reader io.Reader) (*Config, error) {
 	return &configStruct, nil
 }
 
+// fallbackID derives the ID of a probe without one from its name or target and
+// the optional suffix, so that its health state is kept when the configuration is reloaded
+func fallbackID(probe ConfigProbe, suffix string) string {
+	key := probe.Name
+	if key == "" {
+		switch probe.Type() {
+		case "ping":
+			key = probe.Ping.Uri
+		case "socket":
+			key = probe.Socket.Host + ":" + strconv.Itoa(probe.Socket.Port)
+		case "dns":
+			key = probe.DNS.Type + " " + probe.DNS.Name
+		case "postgres":
+			key = probe.Postgres.DSN
+		case "mysql":
+			key = probe.MySQL.DSN
+		default:
+			if len(probe.Requests) > 0 {
+				key = probe.Requests[0].URL
+			}
+		}
+	}
+
+	if suffix != "" {
+		key += "|" + suffix
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	return "probe-" + hex.EncodeToString(hash[:6])
+}
+
+// normalizeAlerts resolves the assertion alias of each alert into its query
+func normalizeAlerts(alerts []ConfigProbeRequestAlert) []ConfigProbeRequestAlert {
+	normalized := make([]ConfigProbeRequestAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		if alert.Query == "" {
+			alert.Query = alert.Assertion
+		}
+		alert.Assertion = ""
+
+		normalized = append(normalized, alert)
+	}
+
+	return normalized
+}
+
+// mapDatabase applies the defaults of a database probe and appends the probe-level alerts
+func mapDatabase(database ConfigProbeDatabase, probeAlerts []ConfigProbeRequestAlert) ConfigProbeDatabase {
+	database.Alerts = append(normalizeAlerts(database.Alerts), probeAlerts...)
+
+	// If timeout is not set, set it to 10 seconds
+	if database.Timeout == 0 {
+		database.Timeout = 10_000 // Default timeout, 10 seconds
+	}
+
+	return database
+}
+
 func GetConfig() *Config {
 	return loadedConfig
 }
@@ -0,0 +1,568 @@
+package loader
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	assertion "hyperjumptech/monika/internal/assertion"
+	notifier "hyperjumptech/monika/internal/notification"
+
+	exprast "github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/robfig/cron/v3"
+)
+
+// ValidationError describes an invalid value in the configuration file
+type ValidationError struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors holds every validation error found in the configuration file
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// supportedMethods lists the HTTP methods accepted in probe requests
+var supportedMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+	"CONNECT": true,
+	"TRACE":   true,
+}
+
+// supportedRecordTypes lists the DNS record types accepted in DNS probes
+var supportedRecordTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CNAME": true,
+	"MX":    true,
+	"NS":    true,
+	"SRV":   true,
+	"TXT":   true,
+}
+
+// The responses mirror the data the alerts of each probe type are evaluated
+// against. They are typed, so that a query on a field the probe type does not
+// have is rejected instead of always comparing against nil.
+type httpResponse struct {
+	Status  int                    `expr:"status"`
+	Time    float64                `expr:"time"`
+	Body    string                 `expr:"body"`
+	Headers map[string]interface{} `expr:"headers"`
+	Size    int                    `expr:"size"`
+}
+
+type pingResponse struct {
+	Time float64 `expr:"time"`
+}
+
+type socketResponse struct {
+	Time  float64 `expr:"time"`
+	Data  string  `expr:"data"`
+	Size  int     `expr:"size"`
+	Error string  `expr:"error"`
+}
+
+type dnsResponse struct {
+	Time    float64       `expr:"time"`
+	Rcode   string        `expr:"rcode"`
+	Answers []interface{} `expr:"answers"`
+	TTLs    []interface{} `expr:"ttls"`
+	Records []interface{} `expr:"records"`
+	Error   string        `expr:"error"`
+}
+
+type databaseResponse struct {
+	Time        float64                `expr:"time"`
+	ConnectTime float64                `expr:"connect_time"`
+	QueryTime   float64                `expr:"query_time"`
+	Rows        int                    `expr:"rows"`
+	Row         map[string]interface{} `expr:"row"`
+	Error       string                 `expr:"error"`
+}
+
+// alertEnvironments mirror the data alert queries are evaluated against for each
+// probe type, so that queries can be compiled before any probe is run
+var alertEnvironments = map[string]map[string]interface{}{
+	"http":     {"response": httpResponse{}},
+	"ping":     {"response": pingResponse{}},
+	"socket":   {"response": socketResponse{}},
+	"dns":      {"response": dnsResponse{}},
+	"postgres": {"response": databaseResponse{}},
+	"mysql":    {"response": databaseResponse{}},
+}
+
+// validator collects validation errors along with their line in the configuration file
+type validator struct {
+	file   *ast.File
+	errors ValidationErrors
+	// notificationIDs are the configured notifications that probes and alerts may refer to
+	notificationIDs map[string]bool
+}
+
+// decodeError converts an error of the YAML decoder into validation errors
+func decodeError(err error) error {
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) {
+		line := 0
+		if token := yamlErr.GetToken(); token != nil {
+			line = token.Position.Line
+		}
+		return ValidationErrors{{Line: line, Path: "$", Message: yamlErr.GetMessage()}}
+	}
+	return err
+}
+
+// addError records a validation error, located at the first path found in the file
+func (v *validator) addError(message string, paths ...string) {
+	v.errors = append(v.errors, ValidationError{
+		Line:    v.lineOf(paths...),
+		Path:    paths[0],
+		Message: message,
+	})
+}
+
+// lineOf returns the line of the first path that exists in the configuration file
+func (v *validator) lineOf(paths ...string) int {
+	if v.file == nil {
+		return 0
+	}
+
+	for _, path := range paths {
+		yamlPath, err := yaml.PathString(path)
+		if err != nil {
+			continue
+		}
+
+		node, err := yamlPath.FilterFile(v.file)
+		if err != nil || node == nil || node.GetToken() == nil {
+			continue
+		}
+
+		return node.GetToken().Position.Line
+	}
+
+	return 0
+}
+
+// validate checks the parsed configuration and returns every error found
+func validate(config *Config, file *ast.File) ValidationErrors {
+	v := &validator{file: file, notificationIDs: make(map[string]bool)}
+	for _, notification := range config.Notifications {
+		v.notificationIDs[notification.ID] = true
+	}
+
+	probeIDs := make(map[string]bool)
+	for probeIndex, probe := range config.Probes {
+		probePath := fmt.Sprintf("$.probes[%d]", probeIndex)
+
+		if probe.ID != "" {
+			if probeIDs[probe.ID] {
+				v.addError(fmt.Sprintf("duplicate probe ID %q", probe.ID), probePath+".id")
+			}
+			probeIDs[probe.ID] = true
+		}
+
+		if probe.Interval < 0 {
+			v.addError("interval must be a positive number of seconds", probePath+".interval")
+		}
+
+		if probe.Type() == "http" && len(probe.Requests) == 0 {
+			v.addError("probe must define at least one request, a ping, a socket, a DNS query or a database", probePath)
+		}
+		if types := probeTypes(probe); len(types) > 1 {
+			v.addError(fmt.Sprintf("probe must define a single type, found %s", strings.Join(types, " and ")), probePath)
+		}
+
+		environment := alertEnvironments[probe.Type()]
+		for requestIndex, request := range probe.Requests {
+			requestPath := fmt.Sprintf("%s.requests[%d]", probePath, requestIndex)
+			v.validateRequest(request, requestPath, environment)
+		}
+
+		for alertIndex, alert := range probe.Ping.Alerts {
+			v.validateAlert(alert, fmt.Sprintf("%s.ping.alerts[%d]", probePath, alertIndex), environment)
+		}
+
+		if probe.Socket.Host != "" {
+			v.validateSocket(probe.Socket, probePath+".socket", environment)
+		}
+		if probe.DNS.Name != "" {
+			v.validateDNS(probe.DNS, probePath+".dns", environment)
+		}
+		if probe.Postgres.DSN != "" {
+			v.validateDatabase(probe.Postgres, probePath+".postgres", environment)
+		}
+		if probe.MySQL.DSN != "" {
+			v.validateDatabase(probe.MySQL, probePath+".mysql", environment)
+		}
+
+		for alertIndex, alert := range probe.Alerts {
+			v.validateAlert(alert, fmt.Sprintf("%s.alerts[%d]", probePath, alertIndex), environment)
+		}
+
+		v.validateRouting(probe.Notifications, probePath+".notifications")
+		v.validateThresholds(probe.SSL.Thresholds, probePath+".ssl.thresholds")
+		v.validateCA(probe.SSL.CA, probePath+".ssl.ca")
+	}
+
+	notificationIDs := make(map[string]bool)
+	for notificationIndex, notification := range config.Notifications {
+		notificationPath := fmt.Sprintf("$.notifications[%d]", notificationIndex)
+
+		if notification.ID == "" {
+			v.addError("notification ID is required", notificationPath+".id", notificationPath)
+		} else {
+			if notificationIDs[notification.ID] {
+				v.addError(fmt.Sprintf("duplicate notification ID %q", notification.ID), notificationPath+".id")
+			}
+			notificationIDs[notification.ID] = true
+		}
+
+		if notification.Timeout < 0 {
+			v.addError("timeout must be a positive number of milliseconds", notificationPath+".timeout")
+		}
+
+		v.validateNotification(notification, notificationPath)
+		v.validateTemplates(notification.Templates, notificationPath+".templates")
+	}
+
+	v.validateDelivery(config.Delivery)
+	v.validateSSL(config.SSL)
+
+	return v.errors
+}
+
+// probeTypes returns the keys of the probe types defined by the probe, only
+// the first one is run
+func probeTypes(probe ConfigProbe) []string {
+	types := make([]string, 0)
+	if len(probe.Requests) > 0 {
+		types = append(types, "requests")
+	}
+	if probe.Ping.Uri != "" {
+		types = append(types, "ping")
+	}
+	if probe.Socket.Host != "" {
+		types = append(types, "socket")
+	}
+	if probe.DNS.Name != "" {
+		types = append(types, "dns")
+	}
+	if probe.Postgres.DSN != "" {
+		types = append(types, "postgres")
+	}
+	if probe.MySQL.DSN != "" {
+		types = append(types, "mysql")
+	}
+	return types
+}
+
+// validateRequest checks the URL, method, timeout and alerts of a probe request
+func (v *validator) validateRequest(request ConfigProbeRequest, path string, environment map[string]interface{}) {
+	if request.URL == "" {
+		v.addError("URL is required", path+".url", path)
+	} else if strings.Contains(request.URL, "{{") {
+		// Templated URLs are only known once the previous requests have been sent
+	} else if parsedURL, err := url.Parse(request.URL); err != nil {
+		v.addError(fmt.Sprintf("invalid URL %q: %s", request.URL, err), path+".url")
+	} else if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		v.addError(fmt.Sprintf("invalid URL %q: scheme must be http or https", request.URL), path+".url")
+	} else if parsedURL.Host == "" {
+		v.addError(fmt.Sprintf("invalid URL %q: missing host", request.URL), path+".url")
+	}
+
+	if request.Method != "" && !supportedMethods[strings.ToUpper(request.Method)] {
+		v.addError(fmt.Sprintf("invalid method %q", request.Method), path+".method")
+	}
+
+	if request.Timeout < 0 {
+		v.addError("timeout must be a positive number of milliseconds", path+".timeout")
+	}
+
+	for alertIndex, alert := range request.Alerts {
+		v.validateAlert(alert, fmt.Sprintf("%s.alerts[%d]", path, alertIndex), environment)
+	}
+}
+
+// validateAlert checks that the alert query compiles
+func (v *validator) validateAlert(alert ConfigProbeRequestAlert, path string, environment map[string]interface{}) {
+	query := alert.Query
+	queryPath := path + ".query"
+	if query == "" {
+		query = alert.Assertion
+		queryPath = path + ".assertion"
+	}
+
+	if query == "" {
+		v.addError("alert query is required", path)
+		return
+	}
+
+	if field, available, unknown := unknownField(query, environment); unknown {
+		v.addError(fmt.Sprintf("invalid alert query %q: unknown field %s, available fields are %s", query, field, strings.Join(available, ", ")), queryPath)
+	} else if err := assertion.Validate(query, environment); err != nil {
+		v.addError(fmt.Sprintf("invalid alert query %q: %s", query, err), queryPath)
+	}
+
+	v.validateRouting(alert.Notifications, path+".notifications")
+}
+
+// unknownField returns the first field the query reads from a value of the
+// environment that does not have it, e.g. response.foo, along with the fields
+// available on that value. The compiler would report it with the name of the
+// Go type of the value instead.
+func unknownField(query string, environment map[string]interface{}) (string, []string, bool) {
+	tree, err := parser.Parse(query)
+	if err != nil {
+		// The syntax error is reported by the compiler
+		return "", nil, false
+	}
+
+	finder := &fieldFinder{environment: environment}
+	exprast.Walk(&tree.Node, finder)
+	return finder.field, finder.available, finder.field != ""
+}
+
+// fieldFinder finds the first unknown field read from a value of the environment
+type fieldFinder struct {
+	environment map[string]interface{}
+	field       string
+	available   []string
+}
+
+// Visit checks the fields read from the values of the environment, e.g. response.status
+func (f *fieldFinder) Visit(node *exprast.Node) {
+	member, isMember := (*node).(*exprast.MemberNode)
+	if !isMember || f.field != "" {
+		return
+	}
+	identifier, isIdentifier := member.Node.(*exprast.IdentifierNode)
+	property, isString := member.Property.(*exprast.StringNode)
+	if !isIdentifier || !isString {
+		return
+	}
+
+	value, exists := f.environment[identifier.Value]
+	if !exists || reflect.TypeOf(value).Kind() != reflect.Struct {
+		return
+	}
+
+	fields := exprFields(reflect.TypeOf(value))
+	for _, field := range fields {
+		if field == property.Value {
+			return
+		}
+	}
+
+	f.field = identifier.Value + "." + property.Value
+	for _, field := range fields {
+		f.available = append(f.available, identifier.Value+"."+field)
+	}
+}
+
+// exprFields returns the names of the fields of a struct in alert queries
+func exprFields(structType reflect.Type) []string {
+	fields := make([]string, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		name := structType.Field(i).Tag.Get("expr")
+		if name == "" {
+			name = structType.Field(i).Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// validateRouting checks that the notification IDs of a probe or alert are configured
+func (v *validator) validateRouting(ids []string, path string) {
+	for index, id := range ids {
+		if !v.notificationIDs[id] {
+			v.addError(fmt.Sprintf("unknown notification ID %q", id), fmt.Sprintf("%s[%d]", path, index), path)
+		}
+	}
+}
+
+// validateDelivery checks that the delivery settings are positive numbers
+func (v *validator) validateDelivery(delivery ConfigDelivery) {
+	if delivery.Attempts < 0 {
+		v.addError("attempts must be a positive number", "$.delivery.attempts")
+	}
+	if delivery.Backoff < 0 {
+		v.addError("backoff must be a positive number of milliseconds", "$.delivery.backoff")
+	}
+	if delivery.MaxBackoff < 0 {
+		v.addError("max_backoff must be a positive number of milliseconds", "$.delivery.max_backoff")
+	}
+	if delivery.Timeout < 0 {
+		v.addError("timeout must be a positive number of milliseconds", "$.delivery.timeout")
+	}
+}
+
+// validateTemplates checks the event types and the syntax of the templates of a notification
+func (v *validator) validateTemplates(templates map[string]ConfigTemplate, path string) {
+	// Sort the event types to report the errors in a stable order
+	eventTypes := make([]string, 0, len(templates))
+	for eventType := range templates {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+
+	for _, eventType := range eventTypes {
+		templatePath := path + "." + eventType
+		if !notifier.IsTemplateEventType(notifier.EventType(eventType)) {
+			supported := make([]string, 0, len(notifier.TemplateEventTypes))
+			for _, supportedType := range notifier.TemplateEventTypes {
+				supported = append(supported, string(supportedType))
+			}
+			v.addError(fmt.Sprintf("unknown event type %q, supported types are: %s", eventType, strings.Join(supported, ", ")), templatePath, path)
+			continue
+		}
+
+		templ := templates[eventType]
+		if _, err := notifier.ParseTemplate(notifier.EventType(eventType), templ.Subject, templ.Body); err != nil {
+			v.addError(err.Error(), templatePath, path)
+		}
+	}
+}
+
+// validateSocket checks the port, timeout and alerts of a socket probe
+func (v *validator) validateSocket(socket ConfigProbeSocket, path string, environment map[string]interface{}) {
+	if socket.Port < 1 || socket.Port > 65535 {
+		v.addError("port must be between 1 and 65535", path+".port", path)
+	}
+	if socket.Timeout < 0 {
+		v.addError("timeout must be a positive number of milliseconds", path+".timeout")
+	}
+
+	for alertIndex, alert := range socket.Alerts {
+		v.validateAlert(alert, fmt.Sprintf("%s.alerts[%d]", path, alertIndex), environment)
+	}
+}
+
+// validateDNS checks the record type, protocol, timeout and alerts of a DNS probe
+func (v *validator) validateDNS(dns ConfigProbeDNS, path string, environment map[string]interface{}) {
+	if dns.Type != "" && !supportedRecordTypes[strings.ToUpper(dns.Type)] {
+		v.addError(fmt.Sprintf("unsupported record type %q, supported types are: A, AAAA, CNAME, MX, NS, SRV, TXT", dns.Type), path+".type")
+	}
+	if protocol := strings.ToLower(dns.Protocol); protocol != "" && protocol != "udp" && protocol != "tcp" {
+		v.addError(fmt.Sprintf("invalid protocol %q, must be udp or tcp", dns.Protocol), path+".protocol")
+	}
+	if dns.Timeout < 0 {
+		v.addError("timeout must be a positive number of milliseconds", path+".timeout")
+	}
+
+	for alertIndex, alert := range dns.Alerts {
+		v.validateAlert(alert, fmt.Sprintf("%s.alerts[%d]", path, alertIndex), environment)
+	}
+}
+
+// validateDatabase checks the timeout and alerts of a database probe
+func (v *validator) validateDatabase(database ConfigProbeDatabase, path string, environment map[string]interface{}) {
+	if database.Timeout < 0 {
+		v.addError("timeout must be a positive number of milliseconds", path+".timeout")
+	}
+
+	for alertIndex, alert := range database.Alerts {
+		v.validateAlert(alert, fmt.Sprintf("%s.alerts[%d]", path, alertIndex), environment)
+	}
+}
+
+// validateSSL checks the thresholds, the schedule, the CA bundle and the audit policy of the SSL checks
+func (v *validator) validateSSL(ssl ConfigSSL) {
+	v.validateThresholds(ssl.Thresholds, "$.ssl.thresholds")
+	v.validateCA(ssl.CA, "$.ssl.ca")
+
+	if _, exists := TLSVersions[ssl.Audit.MinVersion]; ssl.Audit.MinVersion != "" && !exists {
+		v.addError(fmt.Sprintf("invalid TLS version %q, supported versions are: 1.0, 1.1, 1.2, 1.3", ssl.Audit.MinVersion), "$.ssl.audit.min_version")
+	}
+
+	cipherSuites := make(map[string]bool)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		cipherSuites[suite.Name] = true
+	}
+	for index, cipher := range ssl.Audit.WeakCiphers {
+		if !cipherSuites[cipher] {
+			v.addError(fmt.Sprintf("unknown cipher suite %q", cipher), fmt.Sprintf("$.ssl.audit.weak_ciphers[%d]", index), "$.ssl.audit.weak_ciphers")
+		}
+	}
+
+	if ssl.Interval < 0 {
+		v.addError("interval must be a positive number of seconds", "$.ssl.interval")
+	}
+	if ssl.Schedule != "" {
+		if _, err := cron.ParseStandard(ssl.Schedule); err != nil {
+			v.addError(fmt.Sprintf("invalid schedule %q: %s", ssl.Schedule, err), "$.ssl.schedule")
+		}
+	}
+}
+
+// validateThresholds checks that the SSL expiry thresholds are positive numbers of days
+func (v *validator) validateThresholds(thresholds []int, path string) {
+	for index, threshold := range thresholds {
+		if threshold <= 0 {
+			v.addError("threshold must be a positive number of days", fmt.Sprintf("%s[%d]", path, index), path)
+		}
+	}
+}
+
+// validateCA checks that the CA bundle, if set, is a readable PEM file of certificates
+func (v *validator) validateCA(ca string, path string) {
+	if ca == "" {
+		return
+	}
+
+	contents, err := os.ReadFile(ca)
+	if err != nil {
+		v.addError(fmt.Sprintf("invalid CA bundle: %s", err), path)
+		return
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(contents) {
+		v.addError(fmt.Sprintf("invalid CA bundle %q: no PEM certificates found", ca), path)
+	}
+}
+
+// validateNotification checks the type and the channel configuration of a notification
+func (v *validator) validateNotification(notification ConfigNotification, path string) {
+	if !notifier.Registered(notification.Type) {
+		v.addError(fmt.Sprintf("unknown notification type %q, supported types are: %s", notification.Type, strings.Join(notifier.Types(), ", ")), path+".type", path)
+		return
+	}
+
+	// Each channel decodes and validates its own configuration
+	if _, err := notifier.New(notification.Type, notification.Data); err != nil {
+		dataPath := path + ".data"
+		for _, message := range strings.Split(err.Error(), "\n") {
+			v.addError(message, dataPath, path)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package monika
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"hyperjumptech/monika/internal/loader"
+	notifier "hyperjumptech/monika/internal/notification"
+	"hyperjumptech/monika/internal/probers"
+)
+
+// Validate loads the configuration file and returns its validation errors, if any
+func Validate(configPath string) error {
+	_, err := loadConfigFile(configPath)
+	return err
+}
+
+// ProbeOnce runs a single probe, found by its ID or name, once and writes its
+// result to out. It returns an error if the probe cannot be run or if it failed.
+func ProbeOnce(configPath string, probeID string, out io.Writer) error {
+	conf, err := loadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	probe, err := findProbe(conf.Probes, probeID)
+	if err != nil {
+		return err
+	}
+
+	prober, err := probers.New(*probe)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Probe: %s (%s)\n", probe.Name, probe.ID)
+
+	// Print the result of each check
+	result := prober.Probe(context.Background())
+	for _, check := range result.Checks {
+		if check.Error != nil {
+			fmt.Fprintf(out, "%s - Error: %s\n", check.Target, check.Error)
+			continue
+		}
+		fmt.Fprintf(out, "%s - %s\n", check.Target, check.Summary)
+	}
+
+	if result.Failed {
+		return fmt.Errorf("probe %q failed: %s (%s) on %s", probe.ID, result.Reason.AlertMessage, result.Reason.AlertQuery, result.Reason.RequestURL)
+	}
+
+	fmt.Fprintln(out, "Result: healthy")
+	return nil
+}
+
+// findProbe returns the probe with the ID or, as probes without an id are given
+// one derived from their name, with the name. The error lists the available
+// probes if none or several match.
+func findProbe(probes []loader.ConfigProbe, probeID string) (*loader.ConfigProbe, error) {
+	for index := range probes {
+		if probes[index].ID == probeID {
+			return &probes[index], nil
+		}
+	}
+
+	matches := make([]loader.ConfigProbe, 0)
+	for _, probe := range probes {
+		if probe.Name == probeID {
+			matches = append(matches, probe)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return &matches[0], nil
+	case 0:
+		return nil, fmt.Errorf("probe %q not found, available probes:\n%s", probeID, listProbes(probes))
+	default:
+		return nil, fmt.Errorf("several probes are named %q, use one of their IDs:\n%s", probeID, listProbes(matches))
+	}
+}
+
+// listProbes lists the ID and name of each probe, one per line
+func listProbes(probes []loader.ConfigProbe) string {
+	lines := make([]string, 0, len(probes))
+	for _, probe := range probes {
+		lines = append(lines, fmt.Sprintf("  %s (%s)", probe.ID, probe.Name))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NotifyTest sends a test message through a single notification channel
+func NotifyTest(configPath string, notificationID string) error {
+	conf, err := loadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	for _, notification := range conf.Notifications {
+		if notification.ID != notificationID {
+			continue
+		}
+
+		channel, err := notifier.NewChannel(notification.ID, notification.Type, notification.Data)
+		if err != nil {
+			return err
+		}
+		channel.Timeout = time.Duration(notification.Timeout) * time.Millisecond
+		channel.Templates = newTemplates(notification.Templates)
+
+		return channel.Send(context.Background(), notifier.Event{
+			Type:      notifier.EventTest,
+			Timestamp: time.Now(),
+			Message:   "This is a test notification from Monika",
+		})
+	}
+
+	return fmt.Errorf("notification %q not found", notificationID)
+}
+
+// ReplayDeadLetters sends the dead letters again through their channel once and
+// writes the result of each to out. Only the latest incident, incident update or
+// recovery of each probe and channel is sent, the earlier ones are superseded by
+// it. The delivered and superseded dead letters are removed from the dead letter
+// file, it returns an error if some could not be delivered. The dead letter file
+// is locked during the whole replay, so a running Monika waits to record new ones.
+func ReplayDeadLetters(configPath string, out io.Writer) error {
+	conf, err := loadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	notifications := make(map[string]loader.ConfigNotification, len(conf.Notifications))
+	for _, notification := range conf.Notifications {
+		notifications[notification.ID] = notification
+	}
+
+	path := conf.Delivery.DeadLetters
+	total, failed := 0, 0
+	err = notifier.UpdateDeadLetters(path, func(letters []notifier.DeadLetter) []notifier.DeadLetter {
+		total = len(letters)
+		if total == 0 {
+			fmt.Fprintf(out, "No dead letters in %s\n", path)
+			return letters
+		}
+
+		letters, superseded := notifier.CollapseDeadLetters(letters)
+		for _, letter := range superseded {
+			fmt.Fprintf(out, "Skipped %s notification to %s, superseded by a later notification of probe %s\n", letter.Event.Type, letter.ChannelID, letter.Event.ProbeName)
+		}
+
+		channels := make(map[string]notifier.Channel)
+		remaining := make([]notifier.DeadLetter, 0)
+		for _, letter := range letters {
+			err := replayDeadLetter(letter, notifications, channels)
+			switch {
+			case err == nil:
+				fmt.Fprintf(out, "Delivered %s notification to %s\n", letter.Event.Type, letter.ChannelID)
+			case errors.Is(err, notifier.ErrNothingToResolve):
+				fmt.Fprintf(out, "Skipped %s notification to %s: %s\n", letter.Event.Type, letter.ChannelID, err)
+			default:
+				fmt.Fprintf(out, "Failed to deliver %s notification to %s: %s\n", letter.Event.Type, letter.ChannelID, err)
+				letter.Attempts++
+				letter.Error = err.Error()
+				letter.FailedAt = time.Now()
+				remaining = append(remaining, letter)
+			}
+		}
+
+		failed = len(remaining)
+		return remaining
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d dead letters could not be delivered", failed, total)
+	}
+	return nil
+}
+
+// replayDeadLetter sends a dead letter through the channel of the configuration it was recorded for
+func replayDeadLetter(letter notifier.DeadLetter, notifications map[string]loader.ConfigNotification, channels map[string]notifier.Channel) error {
+	channel, exists := channels[letter.ChannelID]
+	if !exists {
+		notification, configured := notifications[letter.ChannelID]
+		if !configured {
+			return fmt.Errorf("notification %q not found", letter.ChannelID)
+		}
+
+		var err error
+		channel, err = notifier.NewChannel(notification.ID, notification.Type, notification.Data)
+		if err != nil {
+			return err
+		}
+		channel.Timeout = time.Duration(notification.Timeout) * time.Millisecond
+		channel.Templates = newTemplates(notification.Templates)
+		channels[letter.ChannelID] = channel
+	}
+
+	return channel.Send(context.Background(), letter.Event)
+}
@@ -1,7 +1,9 @@
 package monika
 
 import (
-	"flag"
+	"context"
+	"errors"
+	"fmt"
 	"hyperjumptech/monika/internal/loader"
 	"hyperjumptech/monika/internal/logger"
 	notifier "hyperjumptech/monika/internal/notification"
@@ -9,38 +11,61 @@ import (
 	"hyperjumptech/monika/tools"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
 
 	CRON "hyperjumptech/monika/internal/cron"
+	ssl "hyperjumptech/monika/internal/cron/jobs"
+
+	// Register the probers
+	_ "hyperjumptech/monika/internal/probers/database"
+	_ "hyperjumptech/monika/internal/probers/dns"
+	_ "hyperjumptech/monika/internal/probers/http"
+	_ "hyperjumptech/monika/internal/probers/ping"
+	_ "hyperjumptech/monika/internal/probers/socket"
+
+	// Register the notification channels
+	_ "hyperjumptech/monika/internal/notification/discord"
+	_ "hyperjumptech/monika/internal/notification/instatus"
+	_ "hyperjumptech/monika/internal/notification/mailgun"
+	_ "hyperjumptech/monika/internal/notification/opsgenie"
+	_ "hyperjumptech/monika/internal/notification/pagerduty"
+	_ "hyperjumptech/monika/internal/notification/pushover"
+	_ "hyperjumptech/monika/internal/notification/sendgrid"
+	_ "hyperjumptech/monika/internal/notification/slack"
+	_ "hyperjumptech/monika/internal/notification/smtp"
+	_ "hyperjumptech/monika/internal/notification/statuspage"
+	_ "hyperjumptech/monika/internal/notification/teams"
+	_ "hyperjumptech/monika/internal/notification/telegram"
+	_ "hyperjumptech/monika/internal/notification/webhook"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-co-op/gocron/v2"
 )
 
-func Init() {
-	// Initialize logger
-	logger := logger.GetLogger()
+// manager runs the probes and reconciles them whenever the configuration is reloaded
+var manager = probers.NewManager()
 
-	// Short flags definitions
-	configShortFlag := flag.String("c", "", "Path to config file")
+// scheduler runs the CRON jobs of the currently loaded configuration
+var scheduler gocron.Scheduler
 
-	// Long flags definitions
-	configLongFlag := flag.String("config", "", "Path to config file")
+// reloadDebounce is the delay between the last change of the config file and its reload
+const reloadDebounce = 500 * time.Millisecond
 
-	// Parse flags
-	flag.Parse()
+// queue delivers the notifications with retries and records the undelivered ones
+var queue *notifier.Queue
 
-	// If config flag is set, use it
-	var fileToRead string
-	if *configShortFlag != "" || *configLongFlag != "" {
-		if *configShortFlag != "" {
-			fileToRead = *configShortFlag
-		} else {
-			fileToRead = *configLongFlag
-		}
-	} else {
-		// If not, use default config file
-		fileToRead = "monika.yml"
-	}
+// sslChecker remembers the notified SSL expiry thresholds across reloads
+var sslChecker = ssl.NewChecker()
+
+// reloadMutex prevents the initial load and a reload from running at the same time
+var reloadMutex sync.Mutex
+
+// Run loads the configuration file, starts the probes and reloads them whenever the file changes
+func Run(fileToRead string) {
+	// Initialize logger
+	logger := logger.GetLogger()
 
 	// Watch for changes in the config file
 	watcher, err := fsnotify.NewWatcher()
@@ -56,8 +81,9 @@ func Init() {
 		os.Exit(1)
 	}
 
-	// Add the config file to the watcher
-	err = watcher.Add(absPath)
+	// Watch the directory of the config file, editors often replace the file
+	// instead of writing to it, which would drop a watch on the file itself
+	err = watcher.Add(filepath.Dir(absPath))
 	if err != nil {
 		logger.Fatal().Str("context", "monika").Str("type", "init").Err(err).Msgf("Failed to watch file: %s", absPath)
 		os.Exit(1)
@@ -65,6 +91,10 @@ func Init() {
 
 	// Start the goroutine to handle events
 	go func() {
+		// Editors usually write a file in several steps, so the reload is debounced
+		// to avoid loading a partially written configuration
+		var reloadTimer *time.Timer
+
 		for {
 			select {
 			case event, ok := <-watcher.Events:
@@ -73,10 +103,23 @@ func Init() {
 					return
 				}
 
+				if filepath.Clean(event.Name) != absPath {
+					continue
+				}
+
 				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
 					logger.Info().Str("context", "monika").Str("type", "watcher").
 						Msgf("File %s has been modified, reloading configuration", event.Name)
-					readConfig(fileToRead)
+					if reloadTimer != nil {
+						reloadTimer.Stop()
+					}
+					reloadTimer = time.AfterFunc(reloadDebounce, func() {
+						// Keep running the last valid configuration if the new one is invalid
+						if err := readConfig(absPath); err != nil {
+							logConfigError(err)
+							logger.Warn().Str("context", "monika").Str("type", "watcher").Msg("Failed to reload configuration, keeping the previous configuration")
+						}
+					})
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -89,38 +132,163 @@ func Init() {
 	}()
 
 	// Read config for the first time
-	readConfig(absPath)
+	if err := readConfig(absPath); err != nil {
+		logConfigError(err)
+		logger.Fatal().Str("context", "monika").Str("type", "init").Msg("Failed to load Monika configuration file")
+		os.Exit(1)
+	}
 }
 
-func readConfig(configPath string) {
-	logger := logger.GetLogger()
+// Stop stops the probes and the CRON jobs, and records the notifications that
+// are still waiting to be delivered as dead letters
+func Stop() {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	manager.Stop()
+	if scheduler != nil {
+		scheduler.Shutdown()
+		scheduler = nil
+	}
+	if queue != nil {
+		queue.Close()
+	}
+}
+
+// loadConfigFile reads and validates the Monika configuration file
+func loadConfigFile(configPath string) (*loader.Config, error) {
 	// Check whether the file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		logger.Fatal().Str("context", "monika").Str("type", "init").Msgf("Monika configuration file does not exists: %s", configPath)
-		os.Exit(1)
+		return nil, fmt.Errorf("Monika configuration file does not exists: %s", configPath)
 	}
 
 	// Read file contents
 	contents, err := os.Open(configPath)
 	if err != nil {
-		logger.Fatal().Str("context", "monika").Str("type", "init").Err(err).Msg("Failed to read Monika configuration file")
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to read Monika configuration file: %w", err)
 	}
 	defer contents.Close()
 
 	// Parse Monika configuration file as a struct
-	conf, err := loader.LoadConfig(contents)
+	return loader.LoadConfig(contents)
+}
+
+// loadedChannel is a notification channel created from its configuration
+type loadedChannel struct {
+	notification loader.ConfigNotification
+	channel      notifier.Channel
+}
+
+// loadedChannels are the channels of the loaded configuration by ID, they are
+// reused on reload if unchanged so they keep their state, e.g. open incidents
+var loadedChannels = make(map[string]loadedChannel)
+
+// newChannels creates the notification channels of the configuration, reusing
+// the channels of the previous configuration that have not changed
+func newChannels(notifications []loader.ConfigNotification, queue *notifier.Queue) notifier.Channels {
+	logger := logger.GetLogger()
+
+	channels := make(notifier.Channels, 0, len(notifications))
+	loaded := make(map[string]loadedChannel, len(notifications))
+	for _, notification := range notifications {
+		channel, reused := reuseChannel(notification)
+		if !reused {
+			var err error
+			channel, err = notifier.NewChannel(notification.ID, notification.Type, notification.Data)
+			if err != nil {
+				logger.Error().Err(err).Str("context", "monika").Str("type", "init").Msgf("Failed to create notification %s", notification.ID)
+				continue
+			}
+		}
+		channel.Timeout = time.Duration(notification.Timeout) * time.Millisecond
+		channel.Templates = newTemplates(notification.Templates)
+		channel.Queue = queue
+
+		channels = append(channels, channel)
+		loaded[notification.ID] = loadedChannel{notification: notification, channel: channel}
+	}
+	loadedChannels = loaded
+
+	return channels
+}
+
+// reuseChannel returns the channel of the previous configuration with the same
+// ID, type and data, its delivery settings may have changed
+func reuseChannel(notification loader.ConfigNotification) (notifier.Channel, bool) {
+	previous, exists := loadedChannels[notification.ID]
+	if !exists || previous.notification.Type != notification.Type || !reflect.DeepEqual(previous.notification.Data, notification.Data) {
+		return notifier.Channel{}, false
+	}
+	return previous.channel, true
+}
+
+// newTemplates parses the message templates of a notification, they have been validated with the configuration
+func newTemplates(templates map[string]loader.ConfigTemplate) notifier.Templates {
+	parsed := make(notifier.Templates, len(templates))
+	for eventType, templ := range templates {
+		parsedTemplate, err := notifier.ParseTemplate(notifier.EventType(eventType), templ.Subject, templ.Body)
+		if err != nil {
+			continue
+		}
+		parsed[notifier.EventType(eventType)] = parsedTemplate
+	}
+	return parsed
+}
+
+// queueConfig returns the retry policy of the delivery configuration
+func queueConfig(delivery loader.ConfigDelivery) notifier.QueueConfig {
+	return notifier.QueueConfig{
+		Attempts:    delivery.Attempts,
+		Backoff:     time.Duration(delivery.Backoff) * time.Millisecond,
+		MaxBackoff:  time.Duration(delivery.MaxBackoff) * time.Millisecond,
+		DeadLetters: delivery.DeadLetters,
+	}
+}
+
+// logConfigError logs each validation error of the configuration file on its own line
+func logConfigError(err error) {
+	logger := logger.GetLogger()
+
+	var validationErrors loader.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		logger.Error().Str("context", "monika").Str("type", "config").Err(err).Msg("Invalid Monika configuration file")
+		return
+	}
+
+	for _, validationError := range validationErrors {
+		logger.Error().Str("context", "monika").Str("type", "config").
+			Int("line", validationError.Line).
+			Str("path", validationError.Path).
+			Msgf("Invalid Monika configuration file: %s", validationError.Message)
+	}
+}
+
+// readConfig loads the configuration file and applies it to the running probes and jobs.
+// If the configuration is invalid, an error is returned and nothing is changed.
+func readConfig(configPath string) error {
+	logger := logger.GetLogger()
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	conf, err := loadConfigFile(configPath)
 	if err != nil {
-		logger.Fatal().Str("context", "monika").Str("type", "init").Err(err).Msg("Failed to parse Monika configuration file")
-		os.Exit(1)
+		return err
 	}
 
 	// Send startup message
 	logger.Info().Str("context", "monika").Str("type", "init").Msgf("Monika configuration loaded from %s", configPath)
 	logger.Info().Str("context", "monika").Str("type", "init").Msgf("Running %d probes with %d notifications", len(conf.Probes), len(conf.Notifications))
-	for _, notification := range conf.Notifications {
-		notifier.SendNotification(notification, "Monika is starting up")
+	if queue == nil {
+		queue = notifier.NewQueue(queueConfig(conf.Delivery))
+	} else {
+		queue.Configure(queueConfig(conf.Delivery))
 	}
+	channels := newChannels(conf.Notifications, queue)
+	channels.Send(context.Background(), notifier.Event{
+		Type:      notifier.EventStartup,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Running %d probes with %d notifications", len(conf.Probes), len(conf.Notifications)),
+	})
 
 	// Run probing
 	go func() {
@@ -131,14 +299,25 @@ func readConfig(configPath string) {
 		}
 	}()
 
+	// Reconcile the running probes with the loaded configuration
+	manager.Apply(conf, channels)
+
+	// Stop the CRON jobs of the previous configuration
+	if scheduler != nil {
+		if err := scheduler.Shutdown(); err != nil {
+			logger.Warn().Err(err).Str("context", "monika").Str("type", "init").Msg("Failed to stop previous CRON scheduler")
+		}
+		scheduler = nil
+	}
+
 	// Initialize CRON jobs
 	cron, err := gocron.NewScheduler()
 	if err != nil {
 		logger.Warn().Err(err).Str("context", "monika").Str("type", "init").Msg("Failed to initialize CRON scheduler, no CRON jobs will be executed.")
-		return
+		return nil
 	}
-	go CRON.StartCron(cron)
+	scheduler = cron
+	CRON.StartCron(cron, conf, sslChecker, channels)
 
-	// Initialize probers
-	probers.InitializeProbes(conf)
+	return nil
 }
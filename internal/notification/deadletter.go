@@ -0,0 +1,210 @@
+package notification
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DeadLetter is an event that could not be delivered through a channel
+type DeadLetter struct {
+	ChannelID   string    `json:"channel_id"`
+	ChannelType string    `json:"channel_type"`
+	Event       Event     `json:"event"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// deadLettersMutex serializes the writes to the dead letter files of this process
+var deadLettersMutex sync.Mutex
+
+// lockDeadLetters locks the dead letter file against the writes of this process
+// and of the other Monika processes, e.g. a running Monika and monika replay.
+// The lock is held on a separate file, as the dead letter file itself is replaced
+// when it is rewritten. It returns the function releasing the lock.
+func lockDeadLetters(path string) (func(), error) {
+	deadLettersMutex.Lock()
+
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		deadLettersMutex.Unlock()
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		deadLettersMutex.Unlock()
+		return nil, fmt.Errorf("failed to lock %s: %w", file.Name(), err)
+	}
+
+	return func() {
+		// Closing the file releases its lock
+		file.Close()
+		deadLettersMutex.Unlock()
+	}, nil
+}
+
+// AppendDeadLetter appends the dead letter to the dead letter file, one JSON object per line
+func AppendDeadLetter(path string, letter DeadLetter) error {
+	encoded, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+
+	unlock, err := lockDeadLetters(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(encoded, '\n'))
+	return err
+}
+
+// ReadDeadLetters reads the dead letters of the dead letter file, which may not exist
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	letters := make([]DeadLetter, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("invalid dead letter on line %d of %s: %w", line, path, err)
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, scanner.Err()
+}
+
+// UpdateDeadLetters replaces the dead letters of the dead letter file with the
+// result of update, no dead letter can be recorded in the meantime
+func UpdateDeadLetters(path string, update func(letters []DeadLetter) []DeadLetter) error {
+	unlock, err := lockDeadLetters(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	letters, err := ReadDeadLetters(path)
+	if err != nil {
+		return err
+	}
+
+	updated := update(letters)
+	if len(letters) == 0 && len(updated) == 0 {
+		// Do not create an empty dead letter file
+		return nil
+	}
+	return writeDeadLetters(path, updated)
+}
+
+// DropIncidentDeadLetters removes the dead letters of the incident of the probe
+// sent through the channel, e.g. once the recovery of the probe has been delivered
+func DropIncidentDeadLetters(path string, channelID string, probeID string) error {
+	unlock, err := lockDeadLetters(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	letters, err := ReadDeadLetters(path)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]DeadLetter, 0, len(letters))
+	for _, letter := range letters {
+		if letter.ChannelID == channelID && letter.Event.ProbeID == probeID && isIncidentEvent(letter.Event.Type) {
+			continue
+		}
+		kept = append(kept, letter)
+	}
+	if len(kept) == len(letters) {
+		return nil
+	}
+	return writeDeadLetters(path, kept)
+}
+
+// CollapseDeadLetters keeps the latest incident, incident update or recovery
+// of each probe and channel, the earlier ones are superseded by it, e.g. an
+// incident resolved by a later recovery. It returns the dead letters to replay,
+// in their original order, and the superseded ones.
+func CollapseDeadLetters(letters []DeadLetter) ([]DeadLetter, []DeadLetter) {
+	latest := make(map[string]int)
+	for index, letter := range letters {
+		if isIncidentEvent(letter.Event.Type) {
+			latest[letter.ChannelID+"|"+letter.Event.ProbeID] = index
+		}
+	}
+
+	kept := make([]DeadLetter, 0, len(letters))
+	superseded := make([]DeadLetter, 0)
+	for index, letter := range letters {
+		if isIncidentEvent(letter.Event.Type) && latest[letter.ChannelID+"|"+letter.Event.ProbeID] != index {
+			superseded = append(superseded, letter)
+			continue
+		}
+		kept = append(kept, letter)
+	}
+	return kept, superseded
+}
+
+// isIncidentEvent reports whether the event is part of the incident of a probe
+func isIncidentEvent(eventType EventType) bool {
+	return eventType == EventIncident || eventType == EventIncidentUpdate || eventType == EventRecovery
+}
+
+// writeDeadLetters replaces the content of the dead letter file with the dead
+// letters, the dead letter file must be locked
+func writeDeadLetters(path string, letters []DeadLetter) error {
+	// Write to a temporary file first so the dead letters are never partially written
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	for _, letter := range letters {
+		encoded, err := json.Marshal(letter)
+		if err != nil {
+			temp.Close()
+			return fmt.Errorf("failed to encode dead letter: %w", err)
+		}
+		writer.Write(append(encoded, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
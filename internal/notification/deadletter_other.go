@@ -0,0 +1,11 @@
+//go:build !unix
+
+package notification
+
+import "os"
+
+// lockFile does nothing where flock is not available, the dead letter file is
+// only locked against the writes of this process
+func lockFile(file *os.File) error {
+	return nil
+}
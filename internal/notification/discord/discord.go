@@ -2,43 +2,84 @@ package discord
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"hyperjumptech/monika/internal/loader"
-	"hyperjumptech/monika/internal/logger"
+	"errors"
+	"fmt"
 	"net/http"
+
+	"hyperjumptech/monika/internal/notification"
 )
 
+func init() {
+	notification.Register("discord", New)
+}
+
+// maxContentLength is the longest message content accepted by Discord
+const maxContentLength = 2000
+
+// Config holds the configuration of a Discord notification
+type Config struct {
+	URL string `yaml:"url"`
+}
+
 type Content struct {
 	Content string `json:"content"`
 }
 
+// Notifier sends events to a Discord webhook
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a Discord notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	if config.URL == "" {
+		return nil, errors.New("Discord webhook URL is required")
+	}
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
 func GeneratePayload(message string) Content {
 	return Content{
 		Content: message,
 	}
 }
 
-func SendNotification(notification loader.ConfigNotification, message Content) {
-	client := &http.Client{}
-	send(client, notification, message)
+// Send posts the event to the Discord webhook
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	return send(ctx, n.client, n.config, GeneratePayload(notification.Truncate(event.Text(), maxContentLength)))
 }
 
-func send(client *http.Client, notification loader.ConfigNotification, message Content) {
-	logger := logger.GetLogger()
-
-	// Create a new HTTP Client
-	defer client.CloseIdleConnections()
-
+func send(ctx context.Context, client *http.Client, config Config, message Content) error {
 	payload := new(bytes.Buffer)
 	err := json.NewEncoder(payload).Encode(message)
 	if err != nil {
-		logger.Error().Err(err).Str("context", "notification").Str("type", "discord").Msg("Failed to encode Discord notification payload")
+		return fmt.Errorf("failed to encode Discord notification payload: %w", err)
 	}
 
-	resp, err := http.Post(notification.Data.URL, "application/json", payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, payload)
 	if err != nil {
-		logger.Error().Err(err).Str("context", "notification").Str("type", "discord").Msg("Failed to send Discord notification")
+		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return nil
 }
@@ -0,0 +1,179 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventType represents what happened to a probe or to Monika
+type EventType string
+
+const (
+	EventIncident    EventType = "incident"
+	EventRecovery    EventType = "recovery"
+	EventSSLExpiring EventType = "ssl-expiring"
+	EventStartup     EventType = "startup"
+	EventTest        EventType = "test"
+
+	// EventIncidentUpdate is sent when a probe in incident triggers another alert
+	EventIncidentUpdate EventType = "incident-update"
+
+	// Problems of the certificate chain presented by an HTTPS server
+	EventSSLHostnameMismatch     EventType = "ssl-hostname-mismatch"
+	EventSSLSelfSigned           EventType = "ssl-self-signed"
+	EventSSLUntrustedRoot        EventType = "ssl-untrusted-root"
+	EventSSLInvalidChain         EventType = "ssl-invalid-chain"
+	EventSSLIntermediateExpiring EventType = "ssl-intermediate-expiring"
+	EventSSLWeakSignature        EventType = "ssl-weak-signature"
+	EventSSLShortKey             EventType = "ssl-short-key"
+
+	// Weaknesses of the TLS configuration of an HTTPS server
+	EventSSLWeakProtocol EventType = "ssl-weak-protocol"
+	EventSSLWeakCipher   EventType = "ssl-weak-cipher"
+
+	// EventSSLResolved is sent when an SSL problem notified before is gone
+	EventSSLResolved EventType = "ssl-resolved"
+)
+
+// IsSSL reports whether the event is about the SSL certificates of a probe
+func (t EventType) IsSSL() bool {
+	return strings.HasPrefix(string(t), "ssl-")
+}
+
+// Event describes what happened to a probe, to be sent through the notification channels
+type Event struct {
+	Type EventType `json:"type"`
+
+	// Probe that triggered the event, empty for startup and test events
+	ProbeID   string `json:"probe_id,omitempty"`
+	ProbeName string `json:"probe_name,omitempty"`
+	Status    string `json:"status,omitempty"`
+
+	// Alert that triggered the incident
+	AlertQuery   string `json:"alert_query,omitempty"`
+	AlertMessage string `json:"alert_message,omitempty"`
+
+	// Request that triggered the event and its response
+	RequestURL     string  `json:"request_url,omitempty"`
+	ResponseStatus int     `json:"response_status,omitempty"`
+	ResponseTime   float64 `json:"response_time,omitempty"`
+
+	// IncidentURL is the URL that caused the ongoing incident, set for incident updates
+	IncidentURL string `json:"incident_url,omitempty"`
+
+	// StartedAt is the time the incident started, set for incident and recovery events
+	StartedAt time.Time `json:"started_at"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Message is a human readable description of the event
+	Message string `json:"message,omitempty"`
+
+	// Issue is the type of the SSL event resolved by an ssl-resolved event
+	Issue EventType `json:"issue,omitempty"`
+
+	// Subject and Body replace the default title and details of the event,
+	// they are rendered from the templates of the channel
+	Subject string `json:"-"`
+	Body    string `json:"-"`
+}
+
+// Title returns a short summary of the event
+func (e Event) Title() string {
+	if e.Subject != "" {
+		return e.Subject
+	}
+
+	switch e.Type {
+	case EventIncident:
+		return fmt.Sprintf("Probe %s is now in an incident state", e.ProbeName)
+	case EventIncidentUpdate:
+		return fmt.Sprintf("Probe %s is still in an incident state", e.ProbeName)
+	case EventRecovery:
+		return fmt.Sprintf("Probe %s is now in a healthy state", e.ProbeName)
+	case EventSSLExpiring:
+		return "SSL certificate is expiring"
+	case EventSSLHostnameMismatch:
+		return "SSL certificate does not match the hostname"
+	case EventSSLSelfSigned:
+		return "SSL certificate is self-signed"
+	case EventSSLUntrustedRoot:
+		return "SSL certificate is not trusted"
+	case EventSSLInvalidChain:
+		return "SSL certificate chain is invalid"
+	case EventSSLIntermediateExpiring:
+		return "SSL intermediate certificate expires before the certificate"
+	case EventSSLWeakSignature:
+		return "SSL certificate has a weak signature"
+	case EventSSLShortKey:
+		return "SSL certificate has a short key"
+	case EventSSLWeakProtocol:
+		return "Server accepts outdated TLS versions"
+	case EventSSLWeakCipher:
+		return "Server accepts weak cipher suites"
+	case EventSSLResolved:
+		return "SSL problem is resolved"
+	case EventStartup:
+		return "Monika is starting up"
+	case EventTest:
+		return "Monika test notification"
+	default:
+		return "Monika notification"
+	}
+}
+
+// Duration returns how long the incident lasted, or has lasted so far
+func (e Event) Duration() time.Duration {
+	if e.StartedAt.IsZero() {
+		return 0
+	}
+
+	end := e.Timestamp
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(e.StartedAt).Truncate(time.Second)
+}
+
+// Text formats the event as plain text, for channels without rich formatting
+func (e Event) Text() string {
+	if e.Body != "" {
+		return e.Title() + "\n\n" + e.Body
+	}
+
+	var builder strings.Builder
+	builder.WriteString(e.Title())
+
+	switch e.Type {
+	case EventIncident, EventIncidentUpdate:
+		builder.WriteString("\n\n")
+		fmt.Fprintf(&builder, "Probe: %s\n", e.ProbeName)
+		fmt.Fprintf(&builder, "Alert: %s\n", e.AlertQuery)
+		fmt.Fprintf(&builder, "Message: %s\n", e.AlertMessage)
+		fmt.Fprintf(&builder, "URL: %s", e.RequestURL)
+	case EventRecovery:
+		builder.WriteString("\n\n")
+		fmt.Fprintf(&builder, "Probe: %s\n", e.ProbeName)
+		if duration := e.Duration(); duration > 0 {
+			fmt.Fprintf(&builder, "Incident duration: %s\n", duration)
+		}
+		builder.WriteString(e.Message)
+	default:
+		if e.Message != "" {
+			builder.WriteString("\n\n")
+			builder.WriteString(e.Message)
+		}
+	}
+
+	return builder.String()
+}
+
+// Truncate shortens the text to at most the given number of characters, without
+// splitting a multi-byte character
+func Truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length])
+}
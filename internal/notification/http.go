@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize limits how much of an error response is included in the error
+const maxErrorBodySize = 512
+
+// SendJSON sends the payload encoded as JSON and returns the response body.
+// It returns an error if the response status is not successful.
+func SendJSON(ctx context.Context, client *http.Client, method string, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode payload: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return Do(client, req)
+}
+
+// Do sends the request and returns the response body.
+// It returns an error if the response status is not successful.
+func Do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(respBody))
+		if len(message) > maxErrorBodySize {
+			message = message[:maxErrorBodySize]
+		}
+		return respBody, fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, message)
+	}
+
+	return respBody, nil
+}
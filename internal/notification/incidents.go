@@ -0,0 +1,76 @@
+package notification
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNothingToResolve is returned by the channels mirroring the probe health
+// when a recovery finds no open incident, the recovery is then not delivered
+// but there is nothing left to deliver either
+var ErrNothingToResolve = errors.New("no open incident to resolve")
+
+// Incidents tracks the remote incident opened for each probe by channels that
+// mirror the probe health, e.g. status pages, so it can be updated and resolved
+type Incidents struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// Get returns the remote incident ID of the probe, if an incident is open
+func (i *Incidents) Get(probeID string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	id, exists := i.ids[probeID]
+	return id, exists
+}
+
+// Set records the remote incident ID opened for the probe
+func (i *Incidents) Set(probeID string, id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.ids == nil {
+		i.ids = make(map[string]string)
+	}
+	i.ids[probeID] = id
+}
+
+// Delete forgets the remote incident of the probe once it is resolved
+func (i *Incidents) Delete(probeID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.ids, probeID)
+}
+
+// RemoteIncident is an incident open on a remote page, e.g. a status page
+type RemoteIncident struct {
+	ID   string
+	Name string
+}
+
+// FindIncident returns the ID of the open incident of the probe among the
+// incidents of a page, e.g. opened before Monika was restarted. Only the default
+// titles Monika gives to the incidents of the probe match, so the incidents of
+// other probes or created by hand are never updated or resolved.
+func FindIncident(incidents []RemoteIncident, probeName string) (string, bool) {
+	if probeName == "" {
+		return "", false
+	}
+
+	// The incident is opened by an incident or, if it was missed, by an update
+	titles := []string{
+		Event{Type: EventIncident, ProbeName: probeName}.Title(),
+		Event{Type: EventIncidentUpdate, ProbeName: probeName}.Title(),
+	}
+	for _, title := range titles {
+		for _, incident := range incidents {
+			if incident.Name == title {
+				return incident.ID, true
+			}
+		}
+	}
+	return "", false
+}
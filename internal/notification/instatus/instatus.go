@@ -0,0 +1,208 @@
+package instatus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("instatus", New)
+}
+
+// defaultBaseURL is the base URL of the Instatus API
+const defaultBaseURL = "https://api.instatus.com"
+
+// Config holds the configuration of an Instatus notification
+type Config struct {
+	APIKey string `yaml:"apiKey"`
+	PageID string `yaml:"pageID"`
+	// BaseURL overrides the Instatus API base URL
+	BaseURL string `yaml:"base_url"`
+}
+
+// Incident is the body of a create incident request
+type Incident struct {
+	Name       string   `json:"name"`
+	Message    string   `json:"message"`
+	Components []string `json:"components"`
+	Started    string   `json:"started"`
+	Status     string   `json:"status"`
+	Notify     bool     `json:"notify"`
+	Statuses   []string `json:"statuses"`
+}
+
+// Update is the body of an add incident update request
+type Update struct {
+	Message    string   `json:"message"`
+	Components []string `json:"components"`
+	Started    string   `json:"started"`
+	Status     string   `json:"status"`
+	Notify     bool     `json:"notify"`
+	Statuses   []string `json:"statuses"`
+}
+
+// Notifier mirrors the probe incidents as incidents of an Instatus page
+type Notifier struct {
+	config    Config
+	client    *http.Client
+	incidents notification.Incidents
+}
+
+// New creates an Instatus notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.APIKey == "" {
+		errs = append(errs, errors.New("Instatus apiKey is required"))
+	}
+	if config.PageID == "" {
+		errs = append(errs, errors.New("Instatus pageID is required"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send creates an incident when a probe becomes an incident, updates it on
+// repeated alerts and resolves it when the probe recovers
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	incidentsURL := fmt.Sprintf("%s/v1/%s/incidents", n.config.BaseURL, url.PathEscape(n.config.PageID))
+	headers := map[string]string{"Authorization": "Bearer " + n.config.APIKey}
+
+	switch event.Type {
+	case notification.EventIncident, notification.EventIncidentUpdate:
+		id, exists, err := n.openIncident(ctx, incidentsURL, headers, event)
+		if err != nil {
+			return err
+		}
+
+		// An incident is already open for the probe, post the new alert to it
+		if exists {
+			return n.update(ctx, incidentsURL, headers, id, "IDENTIFIED", event)
+		}
+
+		body := Incident{
+			Name:       event.Title(),
+			Message:    description(event),
+			Components: []string{},
+			Started:    timestamp(event).Format(time.RFC3339),
+			Status:     "INVESTIGATING",
+			Notify:     true,
+			Statuses:   []string{},
+		}
+		resp, err := notification.SendJSON(ctx, n.client, http.MethodPost, incidentsURL, headers, body)
+		if err != nil {
+			return err
+		}
+
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(resp, &created); err != nil || created.ID == "" {
+			return fmt.Errorf("failed to read the ID of the created incident: %s", resp)
+		}
+		n.incidents.Set(event.ProbeID, created.ID)
+		return nil
+	case notification.EventRecovery:
+		id, exists, err := n.openIncident(ctx, incidentsURL, headers, event)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return notification.ErrNothingToResolve
+		}
+
+		if err := n.update(ctx, incidentsURL, headers, id, "RESOLVED", event); err != nil {
+			return err
+		}
+		n.incidents.Delete(event.ProbeID)
+		return nil
+	case notification.EventTest:
+		// Check the API key and page without publishing anything on the page
+		_, err := notification.SendJSON(ctx, n.client, http.MethodGet, incidentsURL, headers, nil)
+		return err
+	default:
+		return nil
+	}
+}
+
+// openIncident returns the ID of the incident open for the probe. An incident
+// not opened by this notifier, e.g. before Monika was restarted, is looked up
+// among the incidents of the page that are not resolved.
+func (n *Notifier) openIncident(ctx context.Context, incidentsURL string, headers map[string]string, event notification.Event) (string, bool, error) {
+	if id, exists := n.incidents.Get(event.ProbeID); exists {
+		return id, true, nil
+	}
+
+	resp, err := notification.SendJSON(ctx, n.client, http.MethodGet, incidentsURL, headers, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	var incidents []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(resp, &incidents); err != nil {
+		return "", false, fmt.Errorf("failed to read the incidents: %w", err)
+	}
+	remote := make([]notification.RemoteIncident, 0, len(incidents))
+	for _, incident := range incidents {
+		if incident.Status != "RESOLVED" {
+			remote = append(remote, notification.RemoteIncident{ID: incident.ID, Name: incident.Name})
+		}
+	}
+
+	id, exists := notification.FindIncident(remote, event.ProbeName)
+	if exists {
+		n.incidents.Set(event.ProbeID, id)
+	}
+	return id, exists, nil
+}
+
+// update adds an update with the given status to the incident
+func (n *Notifier) update(ctx context.Context, incidentsURL string, headers map[string]string, id string, status string, event notification.Event) error {
+	body := Update{
+		Message:    description(event),
+		Components: []string{},
+		Started:    timestamp(event).Format(time.RFC3339),
+		Status:     status,
+		Notify:     true,
+		Statuses:   []string{},
+	}
+	_, err := notification.SendJSON(ctx, n.client, http.MethodPost, incidentsURL+"/"+url.PathEscape(id)+"/incident-updates", headers, body)
+	return err
+}
+
+// description returns the text of the event without its title
+func description(event notification.Event) string {
+	return strings.TrimPrefix(event.Text(), event.Title()+"\n\n")
+}
+
+// timestamp returns the time of the event
+func timestamp(event notification.Event) time.Time {
+	if event.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return event.Timestamp
+}
@@ -0,0 +1,97 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"hyperjumptech/monika/internal/notification"
+	"hyperjumptech/monika/internal/notification/smtp"
+)
+
+func init() {
+	notification.Register("mailgun", New)
+}
+
+// defaultBaseURL is the base URL of the Mailgun API
+const defaultBaseURL = "https://api.mailgun.net"
+
+// Config holds the configuration of a Mailgun notification
+type Config struct {
+	Recipients []string `yaml:"recipients"`
+	APIKey     string   `yaml:"apiKey"`
+	Domain     string   `yaml:"domain"`
+	// From is the sender address, defaults to monika@<domain>
+	From string `yaml:"from"`
+	// BaseURL overrides the Mailgun API base URL, e.g. https://api.eu.mailgun.net
+	BaseURL string `yaml:"base_url"`
+}
+
+// Notifier sends events by email through the Mailgun API
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a Mailgun notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if len(config.Recipients) == 0 {
+		errs = append(errs, errors.New("Mailgun recipients are required"))
+	}
+	if config.APIKey == "" {
+		errs = append(errs, errors.New("Mailgun apiKey is required"))
+	}
+	if config.Domain == "" {
+		errs = append(errs, errors.New("Mailgun domain is required"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.From == "" {
+		config.From = "Monika <monika@" + config.Domain + ">"
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send sends the event by email to the configured recipients
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	html, err := smtp.RenderHTML(event.Text())
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("from", n.config.From)
+	for _, recipient := range n.config.Recipients {
+		form.Add("to", recipient)
+	}
+	form.Set("subject", event.Title())
+	form.Set("text", event.Text())
+	form.Set("html", html)
+
+	messagesURL := fmt.Sprintf("%s/v3/%s/messages", n.config.BaseURL, url.PathEscape(n.config.Domain))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, messagesURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("api", n.config.APIKey)
+
+	_, err = notification.Do(n.client, req)
+	return err
+}
@@ -1,26 +1,185 @@
 package notification
 
 import (
-	"net/http"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
 
-	"hyperjumptech/monika/internal/loader"
 	"hyperjumptech/monika/internal/logger"
-	"hyperjumptech/monika/internal/notification/discord"
-	"hyperjumptech/monika/internal/notification/smtp"
+
+	"github.com/goccy/go-yaml"
 )
 
-func SendNotification(notification loader.ConfigNotification, message string) {
+// Notifier sends events through a notification channel, e.g. a Discord webhook
+type Notifier interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Factory creates a notifier from the raw data of its configuration.
+// It returns an error if the configuration is invalid.
+type Factory func(data map[string]interface{}) (Notifier, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes a notification channel available for the given type.
+// It is meant to be called from the init function of the channel package.
+func Register(notificationType string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, exists := registry[notificationType]; exists {
+		panic("notification: Register called twice for notification type " + notificationType)
+	}
+	registry[notificationType] = factory
+}
+
+// Registered reports whether a notification channel is available for the given type
+func Registered(notificationType string) bool {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	_, exists := registry[notificationType]
+	return exists
+}
+
+// Types returns the registered notification types
+func Types() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for notificationType := range registry {
+		types = append(types, notificationType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates the notifier registered for the given type from its configuration data
+func New(notificationType string, data map[string]interface{}) (Notifier, error) {
+	registryMutex.RLock()
+	factory, exists := registry[notificationType]
+	registryMutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unsupported notification type: %s", notificationType)
+	}
+	return factory(data)
+}
+
+// DecodeConfig decodes the raw data of a notification configuration into the typed
+// configuration of its channel, rejecting unknown keys
+func DecodeConfig(data map[string]interface{}, config interface{}) error {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	encoded, err := yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.UnmarshalWithOptions(encoded, config, yaml.Strict()); err != nil {
+		// The position of the error refers to the re-encoded data, so only keep the message
+		var yamlErr yaml.Error
+		if errors.As(err, &yamlErr) {
+			return errors.New(yamlErr.GetMessage())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Channel is a configured notification channel
+type Channel struct {
+	ID       string
+	Type     string
+	Notifier Notifier
+	// Timeout bounds each attempt to send an event, there is no timeout if zero
+	Timeout time.Duration
+	// Queue delivers the events of the channel with retries, they are sent directly if nil
+	Queue *Queue
+	// Templates customize the messages of the channel by event type
+	Templates Templates
+}
+
+// NewChannel creates a notification channel from its configuration
+func NewChannel(id string, notificationType string, data map[string]interface{}) (Channel, error) {
+	notifier, err := New(notificationType, data)
+	if err != nil {
+		return Channel{}, err
+	}
+
+	return Channel{ID: id, Type: notificationType, Notifier: notifier}, nil
+}
+
+// Send makes a single attempt to send the event through the channel, logging the failure if any
+func (c Channel) Send(ctx context.Context, event Event) error {
 	logger := logger.GetLogger()
-	// Create a new HTTP Client
-	client := &http.Client{}
-	defer client.CloseIdleConnections()
-
-	switch notification.Type {
-	case "discord":
-		discord.SendNotification(notification, discord.GeneratePayload(message))
-	case "smtp":
-		smtp.SendNotification(notification, smtp.GeneratePayload(message))
-	default:
-		logger.Error().Str("context", "notification").Str("type", "discord").Msgf("Unsupported notification type: %s", notification.Type)
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	// Fall back to the default message if the template cannot be rendered
+	if rendered, err := c.Templates.Apply(event); err != nil {
+		logger.Error().Err(err).Str("context", "notification").Str("type", c.Type).Msgf("Failed to render the %s template of %s, sending the default message", event.Type, c.ID)
+	} else {
+		event = rendered
+	}
+
+	err := c.Notifier.Send(ctx, event)
+	if errors.Is(err, ErrNothingToResolve) {
+		logger.Info().Str("context", "notification").Str("type", c.Type).Msgf("Skipped %s notification to %s: %s", event.Type, c.ID, err)
+		return err
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("context", "notification").Str("type", c.Type).Msgf("Failed to send %s notification to %s", event.Type, c.ID)
+		return err
+	}
+
+	logger.Info().Str("context", "notification").Str("type", c.Type).Msgf("Sent %s notification to %s", event.Type, c.ID)
+	return nil
+}
+
+// Channels are the notification channels of the loaded configuration
+type Channels []Channel
+
+// Select returns the channels with the given IDs, or every channel if no ID is given
+func (c Channels) Select(ids []string) Channels {
+	if len(ids) == 0 {
+		return c
+	}
+
+	selected := make(Channels, 0, len(ids))
+	for _, channel := range c {
+		for _, id := range ids {
+			if channel.ID == id {
+				selected = append(selected, channel)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// Send sends the event through every channel, through the delivery queue of
+// the channels that have one
+func (c Channels) Send(ctx context.Context, event Event) {
+	for _, channel := range c {
+		if channel.Queue != nil {
+			channel.Queue.Enqueue(channel, event)
+			continue
+		}
+		channel.Send(ctx, event)
 	}
 }
@@ -0,0 +1,217 @@
+package opsgenie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("opsgenie", New)
+}
+
+// defaultBaseURL is the base URL of the Opsgenie Alert API
+const defaultBaseURL = "https://api.opsgenie.com"
+
+// testAlias is the alias of the alert created by the test notification
+const testAlias = "monika-test"
+
+// Longest values accepted by the Alert API
+const (
+	maxMessageLength = 130
+	maxTagLength     = 50
+)
+
+// priorities maps the configurable severities to Opsgenie priorities
+var priorities = map[string]string{
+	"critical":      "P1",
+	"high":          "P2",
+	"moderate":      "P3",
+	"low":           "P4",
+	"informational": "P5",
+}
+
+// Config holds the configuration of an Opsgenie notification
+type Config struct {
+	GenieKey string `yaml:"geniekey"`
+	// Severity is the severity of incidents, defaults to critical
+	Severity string `yaml:"severity"`
+	// Tags are added to the tags derived from the probe
+	Tags []string `yaml:"tags"`
+	// BaseURL overrides the Alert API base URL, e.g. https://api.eu.opsgenie.com
+	BaseURL string `yaml:"base_url"`
+}
+
+// Alert is the body of an Opsgenie create alert request
+type Alert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+}
+
+// Close is the body of an Opsgenie close alert request
+type Close struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+// Notifier creates and closes Opsgenie alerts
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates an Opsgenie notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.GenieKey == "" {
+		errs = append(errs, errors.New("Opsgenie geniekey is required"))
+	}
+
+	config.Severity = strings.ToLower(config.Severity)
+	if config.Severity == "" {
+		config.Severity = "critical"
+	}
+	if _, exists := priorities[config.Severity]; !exists {
+		errs = append(errs, fmt.Errorf("invalid Opsgenie severity %q, must be one of: critical, high, moderate, low, informational", config.Severity))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send creates an alert on incidents and closes it by its alias on recovery
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	headers := map[string]string{"Authorization": "GenieKey " + n.config.GenieKey}
+
+	switch event.Type {
+	case notification.EventRecovery:
+		return n.closeAlert(ctx, headers, Alias(event.ProbeID), event.Message)
+	case notification.EventSSLResolved:
+		return n.closeAlert(ctx, headers, sslAlias(event.Issue, event.ProbeID), event.Message)
+	case notification.EventIncident, notification.EventIncidentUpdate:
+		// An update shares the alias of the incident, so it creates the alert
+		// closed on recovery if the channel was not notified of the incident
+		return n.create(ctx, headers, event)
+	case notification.EventTest:
+		// The test checks the API key, its alert is closed right away
+		if err := n.create(ctx, headers, event); err != nil {
+			return err
+		}
+		return n.closeAlert(ctx, headers, testAlias, event.Message)
+	default:
+		if event.Type.IsSSL() {
+			return n.create(ctx, headers, event)
+		}
+		return nil
+	}
+}
+
+// create creates an alert for the event
+func (n *Notifier) create(ctx context.Context, headers map[string]string, event notification.Event) error {
+	_, err := notification.SendJSON(ctx, n.client, http.MethodPost, n.config.BaseURL+"/v2/alerts", headers, n.GenerateAlert(event))
+	return err
+}
+
+// closeAlert closes the alert with the alias
+func (n *Notifier) closeAlert(ctx context.Context, headers map[string]string, alias string, note string) error {
+	closeURL := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", n.config.BaseURL, url.PathEscape(alias))
+	_, err := notification.SendJSON(ctx, n.client, http.MethodPost, closeURL, headers, Close{Source: "Monika", Note: note})
+	return err
+}
+
+// GenerateAlert converts the event into an Opsgenie alert
+func (n *Notifier) GenerateAlert(event notification.Event) Alert {
+	alert := Alert{
+		Message:  event.Title(),
+		Alias:    Alias(event.ProbeID),
+		Entity:   event.ProbeName,
+		Source:   "Monika",
+		Priority: priorities[n.config.Severity],
+		Tags:     n.tags(event),
+		Details:  map[string]string{},
+	}
+	switch {
+	case event.Type.IsSSL():
+		// Keep each kind of certificate alert apart from the incidents of the probe
+		alert.Alias = sslAlias(event.Type, event.ProbeID)
+		alert.Priority = priorities["moderate"]
+	case event.Type == notification.EventTest:
+		alert.Alias = testAlias
+		alert.Priority = priorities["informational"]
+	}
+
+	if event.AlertMessage != "" {
+		alert.Message += ": " + event.AlertMessage
+	}
+	alert.Message = notification.Truncate(alert.Message, maxMessageLength)
+	alert.Description = event.Text()
+
+	if event.ProbeID != "" {
+		alert.Details["probe_id"] = event.ProbeID
+	}
+	if event.AlertQuery != "" {
+		alert.Details["alert"] = event.AlertQuery
+	}
+	if event.RequestURL != "" {
+		alert.Details["url"] = event.RequestURL
+	}
+	if event.ResponseStatus != 0 {
+		alert.Details["response_status"] = fmt.Sprint(event.ResponseStatus)
+	}
+	if event.ResponseTime > 0 {
+		alert.Details["response_time"] = fmt.Sprintf("%.3fms", event.ResponseTime)
+	}
+
+	return alert
+}
+
+// Alias returns the alias of the alert of a probe, so it is closed on recovery
+func Alias(probeID string) string {
+	return "monika-" + probeID
+}
+
+// sslAlias returns the alias of the alert of an SSL problem of a probe, so it
+// is closed once the problem is resolved
+func sslAlias(issue notification.EventType, probeID string) string {
+	return Alias(string(issue) + ":" + probeID)
+}
+
+// tags returns the tags of the alert, derived from the event and the configuration
+func (n *Notifier) tags(event notification.Event) []string {
+	tags := []string{"monika", string(event.Type)}
+	if event.ProbeID != "" {
+		tags = append(tags, "probe:"+event.ProbeID)
+	}
+	if event.ProbeName != "" {
+		tags = append(tags, event.ProbeName)
+	}
+	tags = append(tags, n.config.Tags...)
+
+	for i, tag := range tags {
+		tags[i] = notification.Truncate(tag, maxTagLength)
+	}
+	return tags
+}
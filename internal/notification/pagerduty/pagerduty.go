@@ -0,0 +1,223 @@
+package pagerduty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("pagerduty", New)
+}
+
+// defaultBaseURL is the base URL of the PagerDuty Events API
+const defaultBaseURL = "https://events.pagerduty.com"
+
+// maxSummaryLength is the longest summary accepted by the Events API
+const maxSummaryLength = 1024
+
+// severities are the severities accepted by the Events API
+var severities = []string{"critical", "error", "warning", "info"}
+
+// Config holds the configuration of a PagerDuty notification
+type Config struct {
+	// RoutingKey is the integration key of the PagerDuty service
+	RoutingKey string `yaml:"routing_key"`
+	// Severity is the severity of incidents, defaults to critical
+	Severity string `yaml:"severity"`
+	// BaseURL overrides the Events API base URL
+	BaseURL string `yaml:"base_url"`
+}
+
+// Event is a PagerDuty Events API v2 event
+type Event struct {
+	RoutingKey  string   `json:"routing_key"`
+	EventAction string   `json:"event_action"`
+	DedupKey    string   `json:"dedup_key"`
+	Payload     *Payload `json:"payload,omitempty"`
+}
+
+// Payload describes the alert of a trigger event
+type Payload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// Notifier sends events to the PagerDuty Events API
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a PagerDuty notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.RoutingKey == "" {
+		errs = append(errs, errors.New("PagerDuty routing_key is required"))
+	}
+
+	config.Severity = strings.ToLower(config.Severity)
+	if config.Severity == "" {
+		config.Severity = "critical"
+	}
+	if !isSeverity(config.Severity) {
+		errs = append(errs, fmt.Errorf("invalid PagerDuty severity %q, must be one of: %s", config.Severity, strings.Join(severities, ", ")))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send triggers a PagerDuty incident on incidents and resolves it on recovery
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	pagerDutyEvent, ok := n.GenerateEvent(event)
+	if !ok {
+		return nil
+	}
+
+	enqueueURL := n.config.BaseURL + "/v2/enqueue"
+	if _, err := notification.SendJSON(ctx, n.client, http.MethodPost, enqueueURL, nil, pagerDutyEvent); err != nil {
+		return err
+	}
+
+	// The test checks the routing key, its incident is resolved right away
+	if event.Type == notification.EventTest {
+		resolve := Event{RoutingKey: pagerDutyEvent.RoutingKey, EventAction: "resolve", DedupKey: pagerDutyEvent.DedupKey}
+		_, err := notification.SendJSON(ctx, n.client, http.MethodPost, enqueueURL, nil, resolve)
+		return err
+	}
+	return nil
+}
+
+// GenerateEvent converts the event into a PagerDuty event.
+// It returns false for events that do not concern an incident, e.g. startup.
+func (n *Notifier) GenerateEvent(event notification.Event) (Event, bool) {
+	pagerDutyEvent := Event{
+		RoutingKey:  n.config.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    DedupKey(event.ProbeID, event.RequestURL),
+	}
+
+	severity := n.config.Severity
+	switch event.Type {
+	case notification.EventIncident:
+	case notification.EventIncidentUpdate:
+		// The update triggers the alert of the ongoing incident, which the
+		// recovery resolves, also for channels not notified of the incident
+		pagerDutyEvent.DedupKey = DedupKey(event.ProbeID, event.IncidentURL)
+	case notification.EventRecovery:
+		// The incident is resolved by its dedup key, no payload is needed
+		pagerDutyEvent.EventAction = "resolve"
+		return pagerDutyEvent, true
+	case notification.EventSSLResolved:
+		// The certificate alert is resolved by the dedup key of its problem
+		pagerDutyEvent.EventAction = "resolve"
+		pagerDutyEvent.DedupKey = sslDedupKey(event.Issue, event)
+		return pagerDutyEvent, true
+	case notification.EventTest:
+		pagerDutyEvent.DedupKey = "monika:test"
+		severity = "info"
+	default:
+		if !event.Type.IsSSL() {
+			return Event{}, false
+		}
+		pagerDutyEvent.DedupKey = sslDedupKey(event.Type, event)
+		severity = "warning"
+	}
+
+	pagerDutyEvent.Payload = generatePayload(event, severity)
+	return pagerDutyEvent, true
+}
+
+// DedupKey returns the key identifying the incident of a probe request, so the
+// incident triggered for it is resolved when the probe recovers
+func DedupKey(probeID string, requestURL string) string {
+	hash := sha256.Sum256([]byte(requestURL))
+	return fmt.Sprintf("monika:%s:%s", probeID, hex.EncodeToString(hash[:8]))
+}
+
+// sslDedupKey returns the key identifying the alert of an SSL problem of a
+// probe, kept apart from the incidents of the probe so it is resolved on its own
+func sslDedupKey(issue notification.EventType, event notification.Event) string {
+	return DedupKey(string(issue)+":"+event.ProbeID, event.RequestURL)
+}
+
+// generatePayload describes the event as the payload of a trigger event
+func generatePayload(event notification.Event, severity string) *Payload {
+	summary := event.Title()
+	if event.AlertMessage != "" {
+		summary += ": " + event.AlertMessage
+	} else if event.Message != "" {
+		summary += ": " + event.Message
+	}
+	summary = notification.Truncate(summary, maxSummaryLength)
+
+	source := event.RequestURL
+	if source == "" {
+		source = "monika"
+	}
+
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	details := map[string]interface{}{}
+	if event.ProbeID != "" {
+		details["probe_id"] = event.ProbeID
+	}
+	if event.AlertQuery != "" {
+		details["alert"] = event.AlertQuery
+	}
+	if event.ResponseStatus != 0 {
+		details["response_status"] = event.ResponseStatus
+	}
+	if event.ResponseTime > 0 {
+		details["response_time"] = event.ResponseTime
+	}
+	if event.Message != "" {
+		details["message"] = event.Message
+	}
+
+	return &Payload{
+		Summary:       summary,
+		Source:        source,
+		Severity:      severity,
+		Timestamp:     timestamp.Format(time.RFC3339),
+		Component:     event.ProbeName,
+		CustomDetails: details,
+	}
+}
+
+// isSeverity reports whether the severity is accepted by the Events API
+func isSeverity(severity string) bool {
+	for _, known := range severities {
+		if severity == known {
+			return true
+		}
+	}
+	return false
+}
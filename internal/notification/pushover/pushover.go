@@ -0,0 +1,101 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("pushover", New)
+}
+
+// defaultBaseURL is the base URL of the Pushover API
+const defaultBaseURL = "https://api.pushover.net"
+
+// Longest values accepted by Pushover
+const (
+	maxMessageLength = 1024
+	maxTitleLength   = 250
+)
+
+// Config holds the configuration of a Pushover notification
+type Config struct {
+	// Token is the API token of the Pushover application
+	Token string `yaml:"token"`
+	// User is the key of the user or group receiving the notification
+	User string `yaml:"user"`
+	// BaseURL overrides the Pushover API base URL
+	BaseURL string `yaml:"base_url"`
+}
+
+// Payload is the body of a Pushover messages request
+type Payload struct {
+	Token    string `json:"token"`
+	User     string `json:"user"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Notifier sends events as Pushover push notifications
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a Pushover notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.Token == "" {
+		errs = append(errs, errors.New("Pushover token is required"))
+	}
+	if config.User == "" {
+		errs = append(errs, errors.New("Pushover user is required"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send sends the event as a push notification
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	_, err := notification.SendJSON(ctx, n.client, http.MethodPost, n.config.BaseURL+"/1/messages.json", nil, n.GeneratePayload(event))
+	return err
+}
+
+// GeneratePayload formats the event as a Pushover message, incidents are sent with a high priority
+func (n *Notifier) GeneratePayload(event notification.Event) Payload {
+	// The title is sent separately, the message is the rest of the text if any
+	message := strings.TrimPrefix(event.Text(), event.Title()+"\n\n")
+	message = notification.Truncate(message, maxMessageLength)
+
+	payload := Payload{
+		Token:   n.config.Token,
+		User:    n.config.User,
+		Title:   notification.Truncate(event.Title(), maxTitleLength),
+		Message: message,
+		URL:     event.RequestURL,
+	}
+	if event.Type == notification.EventIncident {
+		payload.Priority = 1
+	}
+
+	return payload
+}
@@ -0,0 +1,227 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"hyperjumptech/monika/internal/logger"
+)
+
+// queueSize is the number of events waiting to be delivered through a channel
+const queueSize = 100
+
+// QueueConfig holds the retry policy of the delivery queue
+type QueueConfig struct {
+	// Attempts is the maximum number of attempts to send an event, including the first one
+	Attempts int
+	// Backoff is the delay before the first retry, doubled on each following retry
+	Backoff time.Duration
+	// MaxBackoff is the longest delay between two attempts
+	MaxBackoff time.Duration
+	// DeadLetters is the file recording the events that could not be delivered
+	DeadLetters string
+}
+
+// delivery is an event waiting to be sent through a channel
+type delivery struct {
+	channel Channel
+	event   Event
+}
+
+// Queue delivers events asynchronously, retrying failed attempts with an
+// exponential backoff. Each channel has its own worker so a failing channel
+// does not delay the others, and events are delivered in order per channel.
+type Queue struct {
+	mu      sync.Mutex
+	config  QueueConfig
+	workers map[string]chan delivery
+	closed  bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewQueue creates a delivery queue with the retry policy
+func NewQueue(config QueueConfig) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		config:  config,
+		workers: make(map[string]chan delivery),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Configure replaces the retry policy, e.g. when the configuration is reloaded
+func (q *Queue) Configure(config QueueConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.config = config
+}
+
+// Enqueue queues the event for delivery through the channel without waiting for it
+func (q *Queue) Enqueue(channel Channel, event Event) {
+	q.mu.Lock()
+	if q.closed {
+		path := q.config.DeadLetters
+		q.mu.Unlock()
+		deadLetter(path, channel, event, 0, errors.New("the delivery queue is closed"))
+		return
+	}
+
+	worker, exists := q.workers[channel.ID]
+	if !exists {
+		worker = make(chan delivery, queueSize)
+		q.workers[channel.ID] = worker
+		q.wg.Add(1)
+		go q.work(worker)
+	}
+
+	select {
+	case worker <- delivery{channel: channel, event: event}:
+		q.mu.Unlock()
+	default:
+		path := q.config.DeadLetters
+		q.mu.Unlock()
+		deadLetter(path, channel, event, 0, errors.New("the delivery queue is full"))
+	}
+}
+
+// Close stops the delivery of the queued events and records them as dead letters
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	for _, worker := range q.workers {
+		close(worker)
+	}
+	q.mu.Unlock()
+
+	q.cancel()
+	q.wg.Wait()
+}
+
+// work delivers the events queued for a channel one at a time
+func (q *Queue) work(deliveries chan delivery) {
+	defer q.wg.Done()
+
+	for delivery := range deliveries {
+		q.deliver(delivery.channel, delivery.event)
+	}
+}
+
+// deliver sends the event through the channel, retrying until it succeeds or
+// the attempts are exhausted, in which case it is recorded as a dead letter
+func (q *Queue) deliver(channel Channel, event Event) {
+	logger := logger.GetLogger()
+
+	q.mu.Lock()
+	config := q.config
+	q.mu.Unlock()
+
+	attempts := max(config.Attempts, 1)
+	for attempt := 1; ; attempt++ {
+		// The queue was closed, keep the event for a replay
+		if q.ctx.Err() != nil {
+			q.record(channel, event, attempt-1, errors.New("Monika stopped before the notification was delivered"))
+			return
+		}
+
+		err := channel.Send(q.ctx, event)
+		if err == nil || errors.Is(err, ErrNothingToResolve) {
+			if event.Type == EventRecovery {
+				q.dropResolved(channel, event)
+			}
+			return
+		}
+		if attempt >= attempts {
+			q.record(channel, event, attempt, err)
+			return
+		}
+
+		delay := Backoff(config.Backoff, config.MaxBackoff, attempt-1)
+		logger.Warn().Str("context", "notification").Str("type", channel.Type).Msgf("Retrying %s notification to %s in %s, attempt %d of %d", event.Type, channel.ID, delay.Round(time.Millisecond), attempt+1, attempts)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-q.ctx.Done():
+			timer.Stop()
+		}
+	}
+}
+
+// record records an undelivered event from a worker as a dead letter
+func (q *Queue) record(channel Channel, event Event, attempts int, err error) {
+	q.mu.Lock()
+	path := q.config.DeadLetters
+	q.mu.Unlock()
+
+	deadLetter(path, channel, event, attempts, err)
+}
+
+// dropResolved removes the dead letters of the incident resolved by the
+// delivered recovery, so that a replay does not open the incident again
+func (q *Queue) dropResolved(channel Channel, recovery Event) {
+	logger := logger.GetLogger()
+
+	q.mu.Lock()
+	path := q.config.DeadLetters
+	q.mu.Unlock()
+
+	if path == "" {
+		return
+	}
+	if err := DropIncidentDeadLetters(path, channel.ID, recovery.ProbeID); err != nil {
+		logger.Error().Err(err).Str("context", "notification").Str("type", channel.Type).Msgf("Failed to remove the resolved incident of %s to %s from %s", recovery.ProbeName, channel.ID, path)
+	}
+}
+
+// deadLetter records an undelivered event in the dead letter file at path,
+// or drops it if there is no dead letter file. The queue must not be locked
+// as the dead letter file may be locked by a replay for a while.
+func deadLetter(path string, channel Channel, event Event, attempts int, err error) {
+	logger := logger.GetLogger()
+
+	if path == "" {
+		logger.Error().Err(err).Str("context", "notification").Str("type", channel.Type).Msgf("Dropped %s notification to %s after %d attempt(s)", event.Type, channel.ID, attempts)
+		return
+	}
+
+	letter := DeadLetter{
+		ChannelID:   channel.ID,
+		ChannelType: channel.Type,
+		Event:       event,
+		Attempts:    attempts,
+		Error:       err.Error(),
+		FailedAt:    time.Now(),
+	}
+	if writeErr := AppendDeadLetter(path, letter); writeErr != nil {
+		logger.Error().Err(writeErr).Str("context", "notification").Str("type", channel.Type).Msgf("Dropped %s notification to %s, failed to save it to %s", event.Type, channel.ID, path)
+		return
+	}
+
+	logger.Error().Err(err).Str("context", "notification").Str("type", channel.Type).Msgf("Gave up sending %s notification to %s after %d attempt(s), saved it to %s", event.Type, channel.ID, attempts, path)
+}
+
+// Backoff returns the delay before a retry, which doubles on each retry up to
+// the maximum. It is randomized between half and all of it to spread the retries.
+func Backoff(base time.Duration, maximum time.Duration, retry int) time.Duration {
+	delay := maximum
+	if retry < 32 && base<<retry > 0 && base<<retry < maximum {
+		delay = base << retry
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
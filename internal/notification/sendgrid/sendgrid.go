@@ -0,0 +1,132 @@
+package sendgrid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"hyperjumptech/monika/internal/logger"
+	"hyperjumptech/monika/internal/notification"
+	"hyperjumptech/monika/internal/notification/smtp"
+)
+
+func init() {
+	notification.Register("sendgrid", New)
+}
+
+// defaultBaseURL is the base URL of the SendGrid API
+const defaultBaseURL = "https://api.sendgrid.com"
+
+// defaultFrom is the sender address used when none is configured
+const defaultFrom = "monika@hyperjump.tech"
+
+// Config holds the configuration of a SendGrid notification
+type Config struct {
+	Recipients []string `yaml:"recipients"`
+	APIKey     string   `yaml:"apiKey"`
+	// From is the sender address, it must be a verified sender of the SendGrid account
+	From string `yaml:"from"`
+	// BaseURL overrides the SendGrid API base URL
+	BaseURL string `yaml:"base_url"`
+}
+
+// Payload is the body of a SendGrid mail send request
+type Payload struct {
+	Personalizations []Personalization `json:"personalizations"`
+	From             Address           `json:"from"`
+	Subject          string            `json:"subject"`
+	Content          []Content         `json:"content"`
+}
+
+// Personalization holds the recipients of the email
+type Personalization struct {
+	To []Address `json:"to"`
+}
+
+// Address is an email address
+type Address struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Content is a body of the email with its MIME type
+type Content struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Notifier sends events by email through the SendGrid API
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a SendGrid notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if len(config.Recipients) == 0 {
+		errs = append(errs, errors.New("SendGrid recipients are required"))
+	}
+	if config.APIKey == "" {
+		errs = append(errs, errors.New("SendGrid apiKey is required"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// SendGrid rejects senders the account has not verified, which the default
+	// sender likely is not, but existing configurations rely on it
+	if config.From == "" {
+		logger := logger.GetLogger()
+		logger.Warn().Str("context", "notification").Str("type", "sendgrid").Msgf("SendGrid from is not set, sending from %s, which must be a verified sender of the SendGrid account", defaultFrom)
+		config.From = defaultFrom
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send sends the event by email to the configured recipients
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	payload, err := n.GeneratePayload(event)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + n.config.APIKey}
+	_, err = notification.SendJSON(ctx, n.client, http.MethodPost, n.config.BaseURL+"/v3/mail/send", headers, payload)
+	return err
+}
+
+// GeneratePayload formats the event as an email with a plain text and an HTML body
+func (n *Notifier) GeneratePayload(event notification.Event) (Payload, error) {
+	html, err := smtp.RenderHTML(event.Text())
+	if err != nil {
+		return Payload{}, err
+	}
+
+	recipients := make([]Address, 0, len(n.config.Recipients))
+	for _, recipient := range n.config.Recipients {
+		recipients = append(recipients, Address{Email: recipient})
+	}
+
+	return Payload{
+		Personalizations: []Personalization{{To: recipients}},
+		From:             Address{Email: n.config.From, Name: "Monika"},
+		Subject:          event.Title(),
+		Content: []Content{
+			{Type: "text/plain", Value: event.Text()},
+			{Type: "text/html", Value: html},
+		},
+	}, nil
+}
@@ -0,0 +1,178 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("slack", New)
+}
+
+// Attachment colours of each event type
+const (
+	colorIncident = "#e01e5a"
+	colorRecovery = "#2eb67d"
+	colorWarning  = "#ecb22e"
+	colorInfo     = "#1d9bd1"
+)
+
+// Longest texts accepted by Block Kit, a longer text fails the whole message
+const (
+	maxHeaderLength  = 150
+	maxSectionLength = 3000
+	maxFieldLength   = 2000
+)
+
+// Config holds the configuration of a Slack notification
+type Config struct {
+	URL string `yaml:"url"`
+}
+
+// Payload is the message posted to a Slack incoming webhook
+type Payload struct {
+	Text        string       `json:"text"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment is a coloured section of a Slack message
+type Attachment struct {
+	Color  string  `json:"color"`
+	Blocks []Block `json:"blocks"`
+}
+
+// Block is a Slack Block Kit block
+type Block struct {
+	Type     string `json:"type"`
+	Text     *Text  `json:"text,omitempty"`
+	Fields   []Text `json:"fields,omitempty"`
+	Elements []Text `json:"elements,omitempty"`
+}
+
+// Text is a Slack Block Kit text object
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Notifier sends events to a Slack incoming webhook
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a Slack notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	if config.URL == "" {
+		return nil, errors.New("Slack webhook URL is required")
+	}
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send posts the event to the Slack incoming webhook
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	_, err := notification.SendJSON(ctx, n.client, http.MethodPost, n.config.URL, nil, GeneratePayload(event))
+	return err
+}
+
+// GeneratePayload formats the event as a Block Kit message with a colour-coded attachment
+func GeneratePayload(event notification.Event) Payload {
+	blocks := []Block{
+		{
+			Type: "header",
+			Text: &Text{Type: "plain_text", Text: notification.Truncate(event.Title(), maxHeaderLength)},
+		},
+	}
+
+	// A templated body replaces the default fields and message
+	if event.Body != "" {
+		blocks = append(blocks, Block{
+			Type: "section",
+			Text: &Text{Type: "mrkdwn", Text: notification.Truncate(event.Body, maxSectionLength)},
+		})
+		return payload(event, blocks)
+	}
+
+	// Describe the probe, alert and response with fields
+	fields := make([]Text, 0)
+	if event.ProbeName != "" {
+		fields = append(fields, field("Probe", event.ProbeName))
+	}
+	if event.AlertQuery != "" {
+		fields = append(fields, field("Alert", fmt.Sprintf("`%s`", event.AlertQuery)))
+	}
+	if event.AlertMessage != "" {
+		fields = append(fields, field("Message", event.AlertMessage))
+	}
+	if event.RequestURL != "" {
+		fields = append(fields, field("URL", event.RequestURL))
+	}
+	if event.ResponseTime > 0 {
+		fields = append(fields, field("Response time", fmt.Sprintf("%.3fms", event.ResponseTime)))
+	}
+	if event.Type == notification.EventRecovery && event.Duration() > 0 {
+		fields = append(fields, field("Incident duration", event.Duration().String()))
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, Block{Type: "section", Fields: fields})
+	}
+
+	if event.Message != "" {
+		blocks = append(blocks, Block{
+			Type: "section",
+			Text: &Text{Type: "mrkdwn", Text: notification.Truncate(event.Message, maxSectionLength)},
+		})
+	}
+
+	return payload(event, blocks)
+}
+
+// payload adds the timestamp to the blocks and wraps them in a colour-coded attachment
+func payload(event notification.Event, blocks []Block) Payload {
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	blocks = append(blocks, Block{
+		Type:     "context",
+		Elements: []Text{{Type: "mrkdwn", Text: "Monika · " + timestamp.Format(time.RFC1123)}},
+	})
+
+	return Payload{
+		Text: event.Title(),
+		Attachments: []Attachment{
+			{Color: color(event.Type), Blocks: blocks},
+		},
+	}
+}
+
+// field formats a labelled field of a section block
+func field(label string, value string) Text {
+	return Text{Type: "mrkdwn", Text: notification.Truncate(fmt.Sprintf("*%s*\n%s", label, value), maxFieldLength)}
+}
+
+// color returns the attachment colour of an event type
+func color(eventType notification.EventType) string {
+	switch eventType {
+	case notification.EventIncident, notification.EventIncidentUpdate:
+		return colorIncident
+	case notification.EventRecovery, notification.EventSSLResolved:
+		return colorRecovery
+	default:
+		if eventType.IsSSL() {
+			return colorWarning
+		}
+		return colorInfo
+	}
+}
@@ -2,15 +2,31 @@ package smtp
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
+	"errors"
+	"fmt"
 	"html/template"
-	"hyperjumptech/monika/internal/loader"
-	"hyperjumptech/monika/internal/logger"
 	"time"
 
+	"hyperjumptech/monika/internal/notification"
+
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
+func init() {
+	notification.Register("smtp", New)
+}
+
+// Config holds the configuration of an SMTP notification
+type Config struct {
+	Recipients []string `yaml:"recipients"`
+	Host       string   `yaml:"hostname"`
+	Port       int      `yaml:"port"`
+	Username   string   `yaml:"username"`
+	Password   string   `yaml:"password"`
+}
+
 type Content struct {
 	Content struct {
 		Body string
@@ -20,6 +36,35 @@ type Content struct {
 //go:embed default-template.html
 var defaultTemplate string
 
+// Notifier sends events by email through an SMTP server
+type Notifier struct {
+	config Config
+}
+
+// New creates an SMTP notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.Host == "" {
+		errs = append(errs, errors.New("SMTP hostname is required"))
+	}
+	if config.Port <= 0 {
+		errs = append(errs, errors.New("SMTP port is required"))
+	}
+	if len(config.Recipients) == 0 {
+		errs = append(errs, errors.New("SMTP recipients are required"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return &Notifier{config: config}, nil
+}
+
 func GeneratePayload(message string) Content {
 	return Content{
 		Content: struct{ Body string }{
@@ -28,54 +73,60 @@ func GeneratePayload(message string) Content {
 	}
 }
 
-func SendNotification(notification loader.ConfigNotification, message Content) {
-	logger := logger.GetLogger()
-	server := mail.NewSMTPClient()
-	host, port, username, password, recipients, ok := notification.Data.GetSMTPConfig()
-	if !ok {
-		return
+// RenderHTML renders the message in the default email template
+func RenderHTML(message string) (string, error) {
+	templ, err := template.New("email-template").Parse(defaultTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse email template: %w", err)
 	}
-	server.Host = host
-	server.Port = port
-	server.Username = username
-	server.Password = password
-	if port == 465 || port == 587 {
+
+	var buffer bytes.Buffer
+	if err := templ.Execute(&buffer, GeneratePayload(message).Content); err != nil {
+		return "", fmt.Errorf("failed to execute email template: %w", err)
+	}
+
+	return buffer.String(), nil
+}
+
+// Send sends the event by email to the configured recipients
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	server := mail.NewSMTPClient()
+	server.Host = n.config.Host
+	server.Port = n.config.Port
+	server.Username = n.config.Username
+	server.Password = n.config.Password
+	if n.config.Port == 465 || n.config.Port == 587 {
 		server.Encryption = mail.EncryptionSTARTTLS
 	}
 	server.KeepAlive = false
 	server.ConnectTimeout = 10 * time.Second
 	server.SendTimeout = 10 * time.Second
-	server.KeepAlive = false
+	// The SMTP client does not use the context, apply its deadline to the timeouts
+	if deadline, ok := ctx.Deadline(); ok {
+		server.ConnectTimeout = time.Until(deadline)
+		server.SendTimeout = time.Until(deadline)
+	}
 	server.Authentication = mail.AuthLogin
 
 	client, err := server.Connect()
 	if err != nil {
-		logger.Error().Err(err).Str("context", "notification").Str("type", "smtp").Msg("Failed to connect to SMTP server")
-		return
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
 	}
 	defer client.Close()
-	email := mail.NewMSG()
-	email.SetFrom("Monika <" + username + ">")
-	email.AddTo(recipients...)
-	email.SetSubject("Monika Notification")
-	templ, err := template.New("email-template").Parse(defaultTemplate)
+
+	body, err := RenderHTML(event.Text())
 	if err != nil {
-		logger.Error().Err(err).Str("context", "notification").Str("type", "smtp").Msg("Failed to parse email template")
-		return
+		return err
 	}
-	var buffer bytes.Buffer
-	if err := templ.Execute(&buffer, message.Content); err != nil {
-		logger.Error().Err(err).Str("context", "notification").Str("type", "smtp").Msg("Failed to execute email template")
-		return
-	}
-	body := buffer.String()
+
+	email := mail.NewMSG()
+	email.SetFrom("Monika <" + n.config.Username + ">")
+	email.AddTo(n.config.Recipients...)
+	email.SetSubject(event.Title())
 	email.SetBody(mail.TextHTML, body)
 	if err := email.Send(client); err != nil {
-		logger.Error().Err(err).Str("context", "notification").Str("type", "smtp").Msg("Failed to send SMTP notification")
-		logger.Error().Err(err).Str("context", "notification").Str("type", "smtp").Msg(err.Error())
-		return
+		return err
 	}
-	logger.Info().Str("context", "notification").Str("type", "smtp").Msg("SMTP notification sent successfully")
-	logger.Debug().Str("context", "notification").Str("type", "smtp").Msgf("SMTP notification sent to %s", recipients)
-	logger.Debug().Str("context", "notification").Str("type", "smtp").Msgf("SMTP notification sent with message: %s", message.Content)
+
+	return nil
 }
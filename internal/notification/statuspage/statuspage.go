@@ -0,0 +1,164 @@
+package statuspage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("statuspage", New)
+}
+
+// defaultBaseURL is the base URL of the Statuspage API
+const defaultBaseURL = "https://api.statuspage.io"
+
+// Config holds the configuration of a Statuspage notification
+type Config struct {
+	APIKey string `yaml:"apiKey"`
+	PageID string `yaml:"pageID"`
+	// BaseURL overrides the Statuspage API base URL
+	BaseURL string `yaml:"base_url"`
+}
+
+// Request is the body of a create or update incident request
+type Request struct {
+	Incident Incident `json:"incident"`
+}
+
+// Incident is a Statuspage incident
+type Incident struct {
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Notifier mirrors the probe incidents as incidents of a Statuspage page
+type Notifier struct {
+	config    Config
+	client    *http.Client
+	incidents notification.Incidents
+}
+
+// New creates a Statuspage notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.APIKey == "" {
+		errs = append(errs, errors.New("Statuspage apiKey is required"))
+	}
+	if config.PageID == "" {
+		errs = append(errs, errors.New("Statuspage pageID is required"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send creates an incident when a probe becomes an incident, updates it on
+// repeated alerts and resolves it when the probe recovers
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	incidentsURL := fmt.Sprintf("%s/v1/pages/%s/incidents", n.config.BaseURL, url.PathEscape(n.config.PageID))
+	headers := map[string]string{"Authorization": "OAuth " + n.config.APIKey}
+
+	switch event.Type {
+	case notification.EventIncident, notification.EventIncidentUpdate:
+		id, exists, err := n.openIncident(ctx, incidentsURL, headers, event)
+		if err != nil {
+			return err
+		}
+
+		// An incident is already open for the probe, post the new alert to it
+		if exists {
+			body := Request{Incident: Incident{Status: "identified", Body: description(event)}}
+			_, err := notification.SendJSON(ctx, n.client, http.MethodPatch, incidentsURL+"/"+url.PathEscape(id), headers, body)
+			return err
+		}
+
+		body := Request{Incident: Incident{Name: event.Title(), Status: "investigating", Body: description(event)}}
+		resp, err := notification.SendJSON(ctx, n.client, http.MethodPost, incidentsURL, headers, body)
+		if err != nil {
+			return err
+		}
+
+		var incident Incident
+		if err := json.Unmarshal(resp, &incident); err != nil || incident.ID == "" {
+			return fmt.Errorf("failed to read the ID of the created incident: %s", resp)
+		}
+		n.incidents.Set(event.ProbeID, incident.ID)
+		return nil
+	case notification.EventRecovery:
+		id, exists, err := n.openIncident(ctx, incidentsURL, headers, event)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return notification.ErrNothingToResolve
+		}
+
+		body := Request{Incident: Incident{Status: "resolved", Body: description(event)}}
+		if _, err := notification.SendJSON(ctx, n.client, http.MethodPatch, incidentsURL+"/"+url.PathEscape(id), headers, body); err != nil {
+			return err
+		}
+		n.incidents.Delete(event.ProbeID)
+		return nil
+	case notification.EventTest:
+		// Check the API key and page without publishing anything on the page
+		_, err := notification.SendJSON(ctx, n.client, http.MethodGet, incidentsURL+"/unresolved", headers, nil)
+		return err
+	default:
+		return nil
+	}
+}
+
+// openIncident returns the ID of the incident open for the probe. An incident
+// not opened by this notifier, e.g. before Monika was restarted, is looked up
+// among the unresolved incidents of the page.
+func (n *Notifier) openIncident(ctx context.Context, incidentsURL string, headers map[string]string, event notification.Event) (string, bool, error) {
+	if id, exists := n.incidents.Get(event.ProbeID); exists {
+		return id, true, nil
+	}
+
+	resp, err := notification.SendJSON(ctx, n.client, http.MethodGet, incidentsURL+"/unresolved", headers, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	var incidents []Incident
+	if err := json.Unmarshal(resp, &incidents); err != nil {
+		return "", false, fmt.Errorf("failed to read the unresolved incidents: %w", err)
+	}
+	remote := make([]notification.RemoteIncident, 0, len(incidents))
+	for _, incident := range incidents {
+		remote = append(remote, notification.RemoteIncident{ID: incident.ID, Name: incident.Name})
+	}
+
+	id, exists := notification.FindIncident(remote, event.ProbeName)
+	if exists {
+		n.incidents.Set(event.ProbeID, id)
+	}
+	return id, exists, nil
+}
+
+// description returns the text of the event without its title
+func description(event notification.Event) string {
+	return strings.TrimPrefix(event.Text(), event.Title()+"\n\n")
+}
@@ -0,0 +1,185 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("teams", New)
+}
+
+// Adaptive Card text colours of each event type
+const (
+	colorIncident = "attention"
+	colorRecovery = "good"
+	colorWarning  = "warning"
+	colorInfo     = "accent"
+)
+
+// Config holds the configuration of a Microsoft Teams notification
+type Config struct {
+	URL string `yaml:"url"`
+}
+
+// Payload is the message posted to a Teams incoming webhook
+type Payload struct {
+	Type        string       `json:"type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment wraps the Adaptive Card of a message
+type Attachment struct {
+	ContentType string `json:"contentType"`
+	Content     Card   `json:"content"`
+}
+
+// Card is an Adaptive Card
+type Card struct {
+	Schema  string    `json:"$schema"`
+	Type    string    `json:"type"`
+	Version string    `json:"version"`
+	Body    []Element `json:"body"`
+}
+
+// Element is an element of the body of an Adaptive Card
+type Element struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Color  string `json:"color,omitempty"`
+	Wrap   bool   `json:"wrap,omitempty"`
+	Facts  []Fact `json:"facts,omitempty"`
+}
+
+// Fact is a labelled value of a fact set
+type Fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Notifier sends events to a Teams incoming webhook
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a Teams notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	if config.URL == "" {
+		return nil, errors.New("Teams webhook URL is required")
+	}
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send posts the event to the Teams incoming webhook
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	_, err := notification.SendJSON(ctx, n.client, http.MethodPost, n.config.URL, nil, GeneratePayload(event))
+	return err
+}
+
+// GeneratePayload formats the event as an Adaptive Card with the incident facts
+func GeneratePayload(event notification.Event) Payload {
+	body := []Element{
+		{
+			Type:   "TextBlock",
+			Text:   event.Title(),
+			Size:   "Large",
+			Weight: "Bolder",
+			Color:  color(event.Type),
+			Wrap:   true,
+		},
+	}
+
+	// A templated body replaces the default facts and message
+	if event.Body != "" {
+		body = append(body, Element{Type: "TextBlock", Text: event.Body, Wrap: true})
+		return payload(event, body)
+	}
+
+	// Describe the probe, alert and response with facts
+	facts := make([]Fact, 0)
+	if event.ProbeName != "" {
+		facts = append(facts, Fact{Title: "Probe", Value: event.ProbeName})
+	}
+	if event.AlertQuery != "" {
+		facts = append(facts, Fact{Title: "Alert", Value: event.AlertQuery})
+	}
+	if event.AlertMessage != "" {
+		facts = append(facts, Fact{Title: "Message", Value: event.AlertMessage})
+	}
+	if event.RequestURL != "" {
+		facts = append(facts, Fact{Title: "URL", Value: event.RequestURL})
+	}
+	if event.ResponseTime > 0 {
+		facts = append(facts, Fact{Title: "Response time", Value: fmt.Sprintf("%.3fms", event.ResponseTime)})
+	}
+	if event.Type == notification.EventRecovery && event.Duration() > 0 {
+		facts = append(facts, Fact{Title: "Incident duration", Value: event.Duration().String()})
+	}
+	if len(facts) > 0 {
+		body = append(body, Element{Type: "FactSet", Facts: facts})
+	}
+
+	if event.Message != "" {
+		body = append(body, Element{Type: "TextBlock", Text: event.Message, Wrap: true})
+	}
+
+	return payload(event, body)
+}
+
+// payload adds the timestamp to the body and wraps it in an Adaptive Card message
+func payload(event notification.Event, body []Element) Payload {
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	body = append(body, Element{
+		Type: "TextBlock",
+		Text: "Monika · " + timestamp.Format(time.RFC1123),
+		Size: "Small",
+		Wrap: true,
+	})
+
+	return Payload{
+		Type: "message",
+		Attachments: []Attachment{
+			{
+				ContentType: "application/vnd.microsoft.card.adaptive",
+				Content: Card{
+					Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+					Type:    "AdaptiveCard",
+					Version: "1.4",
+					Body:    body,
+				},
+			},
+		},
+	}
+}
+
+// color returns the title colour of an event type
+func color(eventType notification.EventType) string {
+	switch eventType {
+	case notification.EventIncident, notification.EventIncidentUpdate:
+		return colorIncident
+	case notification.EventRecovery, notification.EventSSLResolved:
+		return colorRecovery
+	default:
+		if eventType.IsSSL() {
+			return colorWarning
+		}
+		return colorInfo
+	}
+}
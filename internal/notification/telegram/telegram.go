@@ -0,0 +1,170 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("telegram", New)
+}
+
+// defaultBaseURL is the base URL of the Telegram Bot API
+const defaultBaseURL = "https://api.telegram.org"
+
+// maxMessageLength is the longest message text accepted by the Bot API
+const maxMessageLength = 4096
+
+// markdownEscaper escapes the characters reserved by MarkdownV2
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// Config holds the configuration of a Telegram notification
+type Config struct {
+	GroupID  string `yaml:"group_id"`
+	BotToken string `yaml:"bot_token"`
+	// BaseURL overrides the Bot API base URL, e.g. to use a local Bot API server
+	BaseURL string `yaml:"base_url"`
+}
+
+// Payload is the body of a sendMessage request
+type Payload struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"`
+}
+
+// Notifier sends events to a Telegram chat through a bot
+type Notifier struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a Telegram notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.GroupID == "" {
+		errs = append(errs, errors.New("Telegram group_id is required"))
+	}
+	if config.BotToken == "" {
+		errs = append(errs, errors.New("Telegram bot_token is required"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Notifier{config: config, client: &http.Client{}}, nil
+}
+
+// Send sends the event to the Telegram chat
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	url := fmt.Sprintf("%s/bot%s/sendMessage", n.config.BaseURL, n.config.BotToken)
+	payload := Payload{
+		ChatID:    n.config.GroupID,
+		Text:      GenerateMessage(event),
+		ParseMode: "MarkdownV2",
+	}
+
+	_, err := notification.SendJSON(ctx, n.client, http.MethodPost, url, nil, payload)
+	return err
+}
+
+// GenerateMessage formats the event as a MarkdownV2 message. The body or
+// message is truncated so the message fits in a Telegram message.
+func GenerateMessage(event notification.Event) string {
+	title := "*" + Escape(notification.Truncate(event.Title(), maxMessageLength/4)) + "*"
+
+	// A templated body replaces the default details and message
+	if event.Body != "" {
+		return title + "\n\n" + escapeTruncated(event.Body, maxMessageLength-len([]rune(title))-2)
+	}
+
+	// Describe the probe, alert and response with one line each
+	lines := make([]string, 0)
+	line := func(label string, value string) {
+		lines = append(lines, fmt.Sprintf("*%s:* %s", label, value))
+	}
+	if event.ProbeName != "" {
+		line("Probe", Escape(event.ProbeName))
+	}
+	if event.AlertQuery != "" {
+		line("Alert", "`"+escapeCode(event.AlertQuery)+"`")
+	}
+	if event.AlertMessage != "" {
+		line("Message", Escape(event.AlertMessage))
+	}
+	if event.RequestURL != "" {
+		line("URL", Escape(event.RequestURL))
+	}
+	if event.ResponseTime > 0 {
+		line("Response time", Escape(fmt.Sprintf("%.3fms", event.ResponseTime)))
+	}
+	if event.Type == notification.EventRecovery && event.Duration() > 0 {
+		line("Incident duration", Escape(event.Duration().String()))
+	}
+
+	sections := []string{title}
+	if len(lines) > 0 {
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	message := strings.Join(sections, "\n\n")
+	if len([]rune(message)) > maxMessageLength {
+		// Only the title is kept when the details alone do not fit
+		return title
+	}
+
+	if event.Message != "" {
+		message += "\n\n" + escapeTruncated(event.Message, maxMessageLength-len([]rune(message))-2)
+	}
+	return message
+}
+
+// escapeTruncated escapes the text and truncates it to at most length runes
+// once escaped, without splitting an escape sequence
+func escapeTruncated(text string, length int) string {
+	escaped := Escape(text)
+	if len([]rune(escaped)) <= length {
+		return escaped
+	}
+
+	// Cut the text itself, escaping each character doubles it at most
+	var builder strings.Builder
+	remaining := length
+	for _, character := range notification.Truncate(text, length) {
+		escapedCharacter := Escape(string(character))
+		remaining -= len([]rune(escapedCharacter))
+		if remaining < 0 {
+			break
+		}
+		builder.WriteString(escapedCharacter)
+	}
+	return builder.String()
+}
+
+// Escape escapes the text so it is displayed literally in a MarkdownV2 message
+func Escape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// escapeCode escapes the text of an inline code entity, where only ` and \ are reserved
+func escapeCode(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(text)
+}
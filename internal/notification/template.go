@@ -0,0 +1,179 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateEventTypes are the event types whose message can be customized with templates
+var TemplateEventTypes = []EventType{
+	EventIncident,
+	EventIncidentUpdate,
+	EventRecovery,
+	EventSSLExpiring,
+	EventSSLHostnameMismatch,
+	EventSSLSelfSigned,
+	EventSSLUntrustedRoot,
+	EventSSLInvalidChain,
+	EventSSLIntermediateExpiring,
+	EventSSLWeakSignature,
+	EventSSLShortKey,
+	EventSSLWeakProtocol,
+	EventSSLWeakCipher,
+	EventSSLResolved,
+	EventStartup,
+	EventTest,
+}
+
+// Template customizes the subject and the body of the messages of an event type
+type Template struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// Templates are the templates of a channel by event type
+type Templates map[EventType]Template
+
+// TemplateData is the data available to templates, e.g. {{ .Probe.Name }}
+type TemplateData struct {
+	Type     EventType
+	Status   string
+	Probe    TemplateProbe
+	Alert    TemplateAlert
+	Request  TemplateRequest
+	Response TemplateResponse
+	Message  string
+	// Duration is the duration of the incident, set for recovery events
+	Duration  time.Duration
+	StartedAt time.Time
+	Timestamp time.Time
+}
+
+// TemplateProbe is the probe that triggered the event
+type TemplateProbe struct {
+	ID   string
+	Name string
+}
+
+// TemplateAlert is the alert that triggered the incident
+type TemplateAlert struct {
+	Query   string
+	Message string
+}
+
+// TemplateRequest is the request that triggered the event
+type TemplateRequest struct {
+	URL string
+}
+
+// TemplateResponse is the response of the request that triggered the event
+type TemplateResponse struct {
+	Status int
+	Time   float64
+}
+
+// ParseTemplate parses the subject and the body templates of an event type, either may be empty
+func ParseTemplate(eventType EventType, subject string, body string) (Template, error) {
+	var parsed Template
+	var err error
+
+	if subject != "" {
+		parsed.Subject, err = template.New(string(eventType) + " subject").Option("missingkey=error").Parse(subject)
+		if err != nil {
+			return Template{}, fmt.Errorf("invalid subject template: %w", err)
+		}
+	}
+	if body != "" {
+		parsed.Body, err = template.New(string(eventType) + " body").Option("missingkey=error").Parse(body)
+		if err != nil {
+			return Template{}, fmt.Errorf("invalid body template: %w", err)
+		}
+	}
+
+	// Render an empty event to report unknown fields before the first notification
+	data := NewTemplateData(Event{Type: eventType})
+	if _, err := render(parsed.Subject, data); err != nil {
+		return Template{}, fmt.Errorf("invalid subject template: %w", err)
+	}
+	if _, err := render(parsed.Body, data); err != nil {
+		return Template{}, fmt.Errorf("invalid body template: %w", err)
+	}
+
+	return parsed, nil
+}
+
+// IsTemplateEventType reports whether the messages of the event type can be customized
+func IsTemplateEventType(eventType EventType) bool {
+	for _, templateEventType := range TemplateEventTypes {
+		if eventType == templateEventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply renders the template of the event type into the subject and the body of the event
+func (t Templates) Apply(event Event) (Event, error) {
+	templ, exists := t[event.Type]
+	if !exists {
+		return event, nil
+	}
+
+	data := NewTemplateData(event)
+	subject, err := render(templ.Subject, data)
+	if err != nil {
+		return event, err
+	}
+	body, err := render(templ.Body, data)
+	if err != nil {
+		return event, err
+	}
+
+	// A subject is a single line
+	event.Subject = strings.TrimSpace(strings.ReplaceAll(subject, "\n", " "))
+	event.Body = strings.TrimSpace(body)
+	return event, nil
+}
+
+// NewTemplateData returns the data of the event available to templates
+func NewTemplateData(event Event) TemplateData {
+	return TemplateData{
+		Type:   event.Type,
+		Status: event.Status,
+		Probe: TemplateProbe{
+			ID:   event.ProbeID,
+			Name: event.ProbeName,
+		},
+		Alert: TemplateAlert{
+			Query:   event.AlertQuery,
+			Message: event.AlertMessage,
+		},
+		Request: TemplateRequest{
+			URL: event.RequestURL,
+		},
+		Response: TemplateResponse{
+			Status: event.ResponseStatus,
+			Time:   event.ResponseTime,
+		},
+		Message:   event.Message,
+		Duration:  event.Duration(),
+		StartedAt: event.StartedAt,
+		Timestamp: event.Timestamp,
+	}
+}
+
+// render executes the template, an unset template renders nothing
+func render(templ *template.Template, data TemplateData) (string, error) {
+	if templ == nil {
+		return "", nil
+	}
+
+	var buffer bytes.Buffer
+	if err := templ.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"hyperjumptech/monika/internal/notification"
+)
+
+func init() {
+	notification.Register("webhook", New)
+}
+
+// defaultSignatureHeader is the header holding the HMAC signature of the body
+const defaultSignatureHeader = "X-Monika-Signature"
+
+// Config holds the configuration of a webhook notification
+type Config struct {
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+
+	// Secret signs the body with HMAC-SHA256, sent in the signature header
+	Secret          string `yaml:"secret"`
+	SignatureHeader string `yaml:"signature_header"`
+
+	// Template is a Go template rendering the body from the payload,
+	// the payload is sent as JSON if it is not set
+	Template string `yaml:"template"`
+}
+
+// Payload is the JSON event sent to the webhook
+type Payload struct {
+	Type      string          `json:"type"`
+	Status    string          `json:"status"`
+	Probe     PayloadProbe    `json:"probe"`
+	Alert     PayloadAlert    `json:"alert"`
+	Request   PayloadRequest  `json:"request"`
+	Response  PayloadResponse `json:"response"`
+	Message   string          `json:"message"`
+	Subject   string          `json:"subject,omitempty"`
+	Body      string          `json:"body,omitempty"`
+	StartedAt *time.Time      `json:"started_at,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// PayloadProbe identifies the probe that triggered the event
+type PayloadProbe struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// PayloadAlert describes the alert that triggered the incident
+type PayloadAlert struct {
+	Query   string `json:"query"`
+	Message string `json:"message"`
+}
+
+// PayloadRequest describes the request that triggered the event
+type PayloadRequest struct {
+	URL string `json:"url"`
+}
+
+// PayloadResponse describes the response of the request that triggered the event
+type PayloadResponse struct {
+	Status int     `json:"status"`
+	Time   float64 `json:"time"`
+}
+
+// templateFuncs are the functions available in body templates
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// Notifier sends events to a webhook
+type Notifier struct {
+	config   Config
+	template *template.Template
+	client   *http.Client
+}
+
+// New creates a webhook notifier from its configuration data
+func New(data map[string]interface{}) (notification.Notifier, error) {
+	var config Config
+	if err := notification.DecodeConfig(data, &config); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if config.URL == "" {
+		errs = append(errs, errors.New("webhook URL is required"))
+	}
+
+	// Default to POST, the method is validated against the known HTTP methods
+	config.Method = strings.ToUpper(config.Method)
+	switch config.Method {
+	case "":
+		config.Method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodGet, http.MethodDelete:
+	default:
+		errs = append(errs, fmt.Errorf("invalid webhook method %q", config.Method))
+	}
+
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = defaultSignatureHeader
+	}
+
+	notifier := &Notifier{config: config, client: &http.Client{}}
+	if config.Template != "" {
+		templ, err := template.New("webhook").Funcs(templateFuncs).Parse(config.Template)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid webhook template: %w", err))
+		}
+		notifier.template = templ
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return notifier, nil
+}
+
+// GeneratePayload converts the event into the JSON payload of the webhook
+func GeneratePayload(event notification.Event) Payload {
+	payload := Payload{
+		Type:   string(event.Type),
+		Status: event.Status,
+		Probe: PayloadProbe{
+			ID:   event.ProbeID,
+			Name: event.ProbeName,
+		},
+		Alert: PayloadAlert{
+			Query:   event.AlertQuery,
+			Message: event.AlertMessage,
+		},
+		Request: PayloadRequest{
+			URL: event.RequestURL,
+		},
+		Response: PayloadResponse{
+			Status: event.ResponseStatus,
+			Time:   event.ResponseTime,
+		},
+		Message:   event.Message,
+		Subject:   event.Subject,
+		Body:      event.Body,
+		Timestamp: event.Timestamp,
+	}
+	if !event.StartedAt.IsZero() {
+		startedAt := event.StartedAt
+		payload.StartedAt = &startedAt
+	}
+
+	return payload
+}
+
+// Send sends the event to the webhook
+func (n *Notifier) Send(ctx context.Context, event notification.Event) error {
+	body, err := n.render(GeneratePayload(event))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, n.config.Method, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	// Sign the body so the receiver can verify it was sent by Monika
+	if n.config.Secret != "" {
+		req.Header.Set(n.config.SignatureHeader, "sha256="+Sign(body, n.config.Secret))
+	}
+
+	_, err = notification.Do(n.client, req)
+	return err
+}
+
+// render renders the body of the request, using the template if it is set
+func (n *Notifier) render(payload Payload) ([]byte, error) {
+	if n.template == nil {
+		return json.Marshal(payload)
+	}
+
+	var buffer bytes.Buffer
+	if err := n.template.Execute(&buffer, payload); err != nil {
+		return nil, fmt.Errorf("failed to execute webhook template: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the body
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
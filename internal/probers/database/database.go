@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"hyperjumptech/monika/internal/loader"
+	"hyperjumptech/monika/internal/probers"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+func init() {
+	probers.Register("postgres", New)
+	probers.Register("mysql", New)
+}
+
+// DatabaseResult holds the outcome of the connection and the query
+type DatabaseResult struct {
+	// ConnectTime and QueryTime are in milliseconds
+	ConnectTime float64
+	QueryTime   float64
+	Rows        int
+	// Row holds the values of the first row by column name
+	Row map[string]interface{}
+}
+
+// Prober connects to the database of a Postgres or MySQL probe
+type Prober struct {
+	probe    loader.ConfigProbe
+	driver   string
+	database loader.ConfigProbeDatabase
+}
+
+// New creates the prober of a database probe
+func New(probe loader.ConfigProbe) (probers.Prober, error) {
+	switch probe.Type() {
+	case "postgres":
+		return &Prober{probe: probe, driver: "postgres", database: probe.Postgres}, nil
+	case "mysql":
+		return &Prober{probe: probe, driver: "mysql", database: probe.MySQL}, nil
+	default:
+		return nil, errors.New("database probe " + probe.ID + " has no DSN")
+	}
+}
+
+// Probe connects to the database once, runs the query and evaluates its alerts
+func (p *Prober) Probe(ctx context.Context) probers.Result {
+	// The DSN holds credentials, only the server and the database are shown
+	address := Address(p.driver, p.database.DSN)
+	target := strings.ToUpper(p.driver) + " " + address
+
+	// Connection and query errors are available to the alerts as response.error
+	resp, err := sendQuery(ctx, p.driver, p.database)
+	responseTime := resp.ConnectTime + resp.QueryTime
+	environment := map[string]interface{}{
+		"response": map[string]interface{}{
+			"time":         responseTime,
+			"connect_time": resp.ConnectTime,
+			"query_time":   resp.QueryTime,
+			"rows":         resp.Rows,
+			"row":          resp.Row,
+			"error":        probers.ErrorMessage(err),
+		},
+	}
+	check := probers.Check{
+		Target:       target,
+		URL:          address,
+		Summary:      fmt.Sprintf("%d rows - %.3fms", resp.Rows, responseTime),
+		ResponseTime: responseTime,
+		Environment:  environment,
+		Error:        err,
+	}
+
+	return probers.CheckResult(check, p.database.Alerts)
+}
+
+// sendQuery opens a new connection to the database, so that the connection
+// time is measured on every run, and runs the query if it is set
+func sendQuery(ctx context.Context, driver string, database loader.ConfigProbeDatabase) (DatabaseResult, error) {
+	result := DatabaseResult{Row: map[string]interface{}{}}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(database.Timeout)*time.Millisecond)
+	defer cancel()
+
+	db, err := sql.Open(driver, database.DSN)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	start := time.Now()
+	conn, err := db.Conn(ctx)
+	if err == nil {
+		err = conn.PingContext(ctx)
+		defer conn.Close()
+	}
+	result.ConnectTime = milliseconds(time.Since(start))
+	if err != nil || database.Query == "" {
+		return result, err
+	}
+
+	start = time.Now()
+	rows, err := conn.QueryContext(ctx, database.Query)
+	if err != nil {
+		result.QueryTime = milliseconds(time.Since(start))
+		return result, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		result.Rows++
+		if result.Rows > 1 {
+			continue
+		}
+
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for index := range values {
+			pointers[index] = &values[index]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return result, err
+		}
+		for index, column := range columns {
+			result.Row[column.Name()] = convert(values[index], column.DatabaseTypeName())
+		}
+	}
+	err = rows.Err()
+	result.QueryTime = milliseconds(time.Since(start))
+
+	return result, err
+}
+
+// convert returns the value of a column as a number, string, boolean or time,
+// drivers return most values of text protocols as bytes
+func convert(value interface{}, databaseType string) interface{} {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+
+	text := string(bytes)
+	switch {
+	case strings.Contains(databaseType, "INT"):
+		if number, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return number
+		}
+	case strings.Contains(databaseType, "DECIMAL"), strings.Contains(databaseType, "NUMERIC"),
+		strings.Contains(databaseType, "FLOAT"), strings.Contains(databaseType, "DOUBLE"):
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number
+		}
+	}
+	return text
+}
+
+// Address returns the server and the database of the DSN, without its credentials
+func Address(driver string, dsn string) string {
+	if driver == "mysql" {
+		config, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "mysql"
+		}
+		return config.Addr + "/" + config.DBName
+	}
+
+	// Postgres DSNs are either URLs or key=value pairs
+	if parsedURL, err := url.Parse(dsn); err == nil && (parsedURL.Scheme == "postgres" || parsedURL.Scheme == "postgresql") {
+		return parsedURL.Host + parsedURL.Path
+	}
+	settings := make(map[string]string)
+	for _, field := range strings.Fields(dsn) {
+		if key, value, found := strings.Cut(field, "="); found {
+			settings[key] = strings.Trim(value, "'")
+		}
+	}
+	address := settings["host"]
+	if address == "" {
+		address = "localhost"
+	}
+	if settings["port"] != "" {
+		address += ":" + settings["port"]
+	}
+	return address + "/" + settings["dbname"]
+}
+
+// milliseconds converts the duration to milliseconds
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1_000
+}
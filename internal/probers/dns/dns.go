@@ -0,0 +1,197 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"hyperjumptech/monika/internal/loader"
+	"hyperjumptech/monika/internal/probers"
+
+	"github.com/miekg/dns"
+)
+
+func init() {
+	probers.Register("dns", New)
+}
+
+// resolvConf is the configuration of the system resolver
+const resolvConf = "/etc/resolv.conf"
+
+// DNSResult holds the response of a DNS query
+type DNSResult struct {
+	Rcode   string
+	Answers []string
+	TTLs    []int
+	Records []Record
+	// ResponseTime is the round trip time of the query in milliseconds
+	ResponseTime float64
+}
+
+// Record is a resource record of the answer section
+type Record struct {
+	Name  string
+	Type  string
+	TTL   int
+	Value string
+}
+
+// Prober queries the record of a DNS probe
+type Prober struct {
+	probe loader.ConfigProbe
+}
+
+// New creates the prober of a DNS probe
+func New(probe loader.ConfigProbe) (probers.Prober, error) {
+	if probe.DNS.Name == "" {
+		return nil, errors.New("DNS probe " + probe.ID + " has no name")
+	}
+	if _, exists := dns.StringToType[probe.DNS.Type]; !exists {
+		return nil, fmt.Errorf("DNS probe %s has an unsupported record type %q", probe.ID, probe.DNS.Type)
+	}
+
+	return &Prober{probe: probe}, nil
+}
+
+// Probe queries the record once and evaluates its alerts
+func (p *Prober) Probe(ctx context.Context) probers.Result {
+	server, err := resolver(p.probe.DNS.Server)
+	target := fmt.Sprintf("DNS %s %s @%s", p.probe.DNS.Type, p.probe.DNS.Name, server)
+
+	// Query errors are available to the alerts as response.error
+	resp := DNSResult{}
+	if err == nil {
+		resp, err = sendQuery(ctx, p.probe.DNS, server)
+	}
+
+	records := make([]interface{}, 0, len(resp.Records))
+	for _, record := range resp.Records {
+		records = append(records, map[string]interface{}{
+			"name":  record.Name,
+			"type":  record.Type,
+			"ttl":   record.TTL,
+			"value": record.Value,
+		})
+	}
+	answers := make([]interface{}, 0, len(resp.Answers))
+	for _, answer := range resp.Answers {
+		answers = append(answers, answer)
+	}
+	ttls := make([]interface{}, 0, len(resp.TTLs))
+	for _, ttl := range resp.TTLs {
+		ttls = append(ttls, ttl)
+	}
+	environment := map[string]interface{}{
+		"response": map[string]interface{}{
+			"time":    resp.ResponseTime,
+			"rcode":   resp.Rcode,
+			"answers": answers,
+			"ttls":    ttls,
+			"records": records,
+			"error":   probers.ErrorMessage(err),
+		},
+	}
+	check := probers.Check{
+		Target:       target,
+		URL:          p.probe.DNS.Name,
+		Summary:      fmt.Sprintf("%s - %s - %.3fms", resp.Rcode, strings.Join(resp.Answers, ", "), resp.ResponseTime),
+		ResponseTime: resp.ResponseTime,
+		Environment:  environment,
+		Error:        err,
+	}
+
+	return probers.CheckResult(check, p.probe.DNS.Alerts)
+}
+
+// sendQuery queries the record from the server, over TCP if the UDP response is truncated
+func sendQuery(ctx context.Context, query loader.ConfigProbeDNS, server string) (DNSResult, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(query.Name), dns.StringToType[query.Type])
+
+	client := &dns.Client{
+		Net:     query.Protocol,
+		Timeout: time.Duration(query.Timeout) * time.Millisecond,
+	}
+	resp, rtt, err := client.ExchangeContext(ctx, msg, server)
+	if err == nil && resp.Truncated && client.Net == "udp" {
+		client.Net = "tcp"
+		resp, rtt, err = client.ExchangeContext(ctx, msg, server)
+	}
+	if err != nil {
+		return DNSResult{}, err
+	}
+
+	result := DNSResult{
+		Rcode:        dns.RcodeToString[resp.Rcode],
+		Answers:      make([]string, 0),
+		TTLs:         make([]int, 0),
+		Records:      make([]Record, 0, len(resp.Answer)),
+		ResponseTime: float64(rtt.Microseconds()) / 1_000,
+	}
+	for _, rr := range resp.Answer {
+		header := rr.Header()
+		record := Record{
+			Name:  strings.TrimSuffix(header.Name, "."),
+			Type:  dns.TypeToString[header.Rrtype],
+			TTL:   int(header.Ttl),
+			Value: value(rr),
+		}
+		result.Records = append(result.Records, record)
+
+		// The answers are the records of the queried type, e.g. not the CNAME leading to an A record
+		if record.Type == query.Type {
+			result.Answers = append(result.Answers, record.Value)
+			result.TTLs = append(result.TTLs, record.TTL)
+		}
+	}
+
+	return result, nil
+}
+
+// value returns the data of the record, e.g. the address of an A record
+func value(rr dns.RR) string {
+	switch record := rr.(type) {
+	case *dns.A:
+		return record.A.String()
+	case *dns.AAAA:
+		return record.AAAA.String()
+	case *dns.CNAME:
+		return strings.TrimSuffix(record.Target, ".")
+	case *dns.MX:
+		return strings.TrimSuffix(record.Mx, ".")
+	case *dns.NS:
+		return strings.TrimSuffix(record.Ns, ".")
+	case *dns.TXT:
+		return strings.Join(record.Txt, "")
+	case *dns.SRV:
+		return net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+	default:
+		return strings.TrimPrefix(rr.String(), rr.Header().String())
+	}
+}
+
+// resolver returns the address of the server, defaulting to port 53, or of
+// the system resolver if it is not set
+func resolver(server string) (string, error) {
+	if server == "" {
+		config, err := dns.ClientConfigFromFile(resolvConf)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the system resolver: %w", err)
+		}
+		if len(config.Servers) == 0 {
+			return "", errors.New("no system resolver configured in " + resolvConf)
+		}
+		return net.JoinHostPort(config.Servers[0], config.Port), nil
+	}
+
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server, nil
+	}
+	// IPv6 addresses without a port may be bracketed, e.g. [::1]
+	host := strings.TrimSuffix(strings.TrimPrefix(server, "["), "]")
+	return net.JoinHostPort(host, "53"), nil
+}
@@ -0,0 +1,269 @@
+package probers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"hyperjumptech/monika/internal/loader"
+	"hyperjumptech/monika/internal/logger"
+	notifier "hyperjumptech/monika/internal/notification"
+)
+
+// ProbeStatus represents the status of a probe
+type ProbeStatus string
+
+const (
+	HEALTHY  ProbeStatus = "Healthy"
+	INCIDENT ProbeStatus = "Incident"
+)
+
+// Default thresholds for probes without requests, e.g. ping probes
+const (
+	defaultIncidentThreshold = 5
+	defaultRecoveryThreshold = 5
+)
+
+// ProbeHealth holds the current status of a probe and its thresholds
+type ProbeHealth struct {
+	Status            ProbeStatus
+	IncidentCount     int
+	RecoveryCount     int
+	RecoveryThreshold int
+	IncidentThreshold int
+	IncidentStartedAt time.Time
+	// IncidentURL is the URL that caused the ongoing incident, reported again on recovery
+	IncidentURL string
+	// IncidentAlerts are the queries of the alerts already notified for the ongoing incident
+	IncidentAlerts map[string]bool
+	// Notifications are the notifications of the ongoing incident and its updates,
+	// which also receive its recovery
+	Notifications []string
+}
+
+// NewProbeHealth creates the initial health state of a probe
+func NewProbeHealth(probe loader.ConfigProbe) *ProbeHealth {
+	probeHealth := &ProbeHealth{
+		Status:            HEALTHY,
+		RecoveryThreshold: defaultRecoveryThreshold,
+		IncidentThreshold: defaultIncidentThreshold,
+	}
+	if len(probe.Requests) == 0 {
+		return probeHealth
+	}
+
+	// Determine the largest recovery and incident thresholds
+	probeHealth.RecoveryThreshold = probe.Requests[0].RecoveryThreshold
+	probeHealth.IncidentThreshold = probe.Requests[0].IncidentThreshold
+	for _, request := range probe.Requests {
+		if request.RecoveryThreshold > probeHealth.RecoveryThreshold {
+			probeHealth.RecoveryThreshold = request.RecoveryThreshold
+		}
+
+		if request.IncidentThreshold > probeHealth.IncidentThreshold {
+			probeHealth.IncidentThreshold = request.IncidentThreshold
+		}
+	}
+
+	return probeHealth
+}
+
+// runProbe runs the prober at the probe interval until the context is cancelled,
+// and notifies the configured channel(s) when the probe status changes
+func runProbe(ctx context.Context, probe loader.ConfigProbe, prober Prober, channels notifier.Channels, probeHealth *ProbeHealth) {
+	logger := logger.GetLogger()
+
+	interval := time.Duration(probe.Interval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result := prober.Probe(ctx)
+
+		// The probe may have been stopped while the checks were in flight
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Log the result of each check
+		for _, check := range result.Checks {
+			if check.Error != nil {
+				logger.Info().Str("context", "probe").Str("type", probe.Type()).Msgf("%s - %s - %s - Error: %s", probe.Name, probeHealth.Status, check.Target, check.Error.Error())
+				continue
+			}
+			logger.Info().Str("context", "probe").Str("type", probe.Type()).Msgf("%s - %s - %s - %s", probe.Name, probeHealth.Status, check.Target, check.Summary)
+		}
+
+		if event, notifications, changed := probeHealth.update(probe, result); changed {
+			// Send notification to the configured channel(s)
+			channels.Select(notifications).Send(ctx, event)
+		}
+	}
+}
+
+// update applies the result of a probe run to the probe health. It returns the
+// event to notify and the notifications receiving it when an incident or a
+// recovery threshold is reached, or when an incident triggers another alert.
+func (probeHealth *ProbeHealth) update(probe loader.ConfigProbe, result Result) (notifier.Event, []string, bool) {
+	logger := logger.GetLogger()
+	reason := result.Reason
+
+	if result.Failed {
+		// If any check failed, handle incident detection
+		// Add the incident count and reset the recovery count
+		probeHealth.IncidentCount++
+		probeHealth.RecoveryCount = 0
+
+		// A probe already in incident is notified again only when an alert not
+		// notified yet during the incident is triggered
+		if probeHealth.Status != HEALTHY {
+			if probeHealth.IncidentAlerts[reason.AlertQuery] {
+				return notifier.Event{}, nil, false
+			}
+
+			logger.Info().Str("context", "probe").Str("type", probe.Type()).Msgf("Probe %s is still unhealthy with another alert: %s, sending notification to the configured channel(s)", probe.Name, reason.AlertMessage)
+			event := probeHealth.newEvent(notifier.EventIncidentUpdate, probe, result)
+			event.AlertQuery = reason.AlertQuery
+			event.AlertMessage = reason.AlertMessage
+			event.IncidentURL = probeHealth.IncidentURL
+			probeHealth.IncidentAlerts[reason.AlertQuery] = true
+
+			// Route the update like an incident, its notifications also receive the recovery
+			notifications := routeAlert(probe, reason)
+			probeHealth.Notifications = mergeNotifications(probeHealth.Notifications, notifications)
+			return event, notifications, true
+		}
+
+		// If the incident count is lower than the incident threshold, just log
+		if probeHealth.IncidentCount < probeHealth.IncidentThreshold {
+			logger.Info().Str("context", "probe").Str("type", probe.Type()).Msgf("Alert detected for probe %s: %s. Attempt %d of %d until it may be considered an incident", probe.Name, reason.AlertMessage, probeHealth.IncidentCount, probeHealth.IncidentThreshold)
+			return notifier.Event{}, nil, false
+		}
+
+		probeHealth.Status = INCIDENT
+		probeHealth.IncidentStartedAt = time.Now()
+
+		logger.Info().Str("context", "probe").Str("type", probe.Type()).Msgf("Probe %s is unhealthy, sending notification to the configured channel(s)", probe.Name)
+		event := probeHealth.newEvent(notifier.EventIncident, probe, result)
+		event.AlertQuery = reason.AlertQuery
+		event.AlertMessage = reason.AlertMessage
+		probeHealth.IncidentURL = event.RequestURL
+		probeHealth.IncidentAlerts = map[string]bool{reason.AlertQuery: true}
+
+		probeHealth.Notifications = routeAlert(probe, reason)
+		return event, probeHealth.Notifications, true
+	}
+
+	// If all checks were successful, handle recovery detection
+	// Add the recovery count and reset the incident count
+	probeHealth.RecoveryCount++
+	probeHealth.IncidentCount = 0
+
+	// Only a probe in incident can recover
+	if probeHealth.Status != INCIDENT {
+		return notifier.Event{}, nil, false
+	}
+
+	// If the recovery count is lower than the recovery threshold, just log
+	if probeHealth.RecoveryCount < probeHealth.RecoveryThreshold {
+		logger.Info().Str("context", "probe").Str("type", probe.Type()).Msgf("Probe %s is recovering, attempt %d of %d until it may be considered a recovery", probe.Name, probeHealth.RecoveryCount, probeHealth.RecoveryThreshold)
+		return notifier.Event{}, nil, false
+	}
+
+	probeHealth.Status = HEALTHY
+
+	logger.Info().Str("context", "probe").Str("type", probe.Type()).Msgf("Probe %s is healthy, sending notification to the configured channel(s)", probe.Name)
+	event := probeHealth.newEvent(notifier.EventRecovery, probe, result)
+	event.Message = fmt.Sprintf("All checks passed successfully for %d consecutive attempts", probeHealth.RecoveryThreshold)
+	if probeHealth.IncidentURL != "" {
+		event.RequestURL = probeHealth.IncidentURL
+	}
+	probeHealth.IncidentStartedAt = time.Time{}
+	probeHealth.IncidentURL = ""
+	probeHealth.IncidentAlerts = nil
+	return event, probeHealth.Notifications, true
+}
+
+// resume carries the ongoing incident of the previous health state of a changed
+// probe over, so that the incident is still resolved when the probe recovers
+func (probeHealth *ProbeHealth) resume(previous *ProbeHealth) {
+	if previous.Status != INCIDENT {
+		return
+	}
+
+	probeHealth.Status = INCIDENT
+	probeHealth.IncidentStartedAt = previous.IncidentStartedAt
+	probeHealth.IncidentURL = previous.IncidentURL
+	probeHealth.IncidentAlerts = previous.IncidentAlerts
+	probeHealth.Notifications = previous.Notifications
+}
+
+// resolve returns the recovery event closing the ongoing incident of a probe
+// that is no longer run, e.g. removed from the configuration, if it is in incident
+func (probeHealth *ProbeHealth) resolve(probe loader.ConfigProbe, reason string) (notifier.Event, bool) {
+	if probeHealth.Status != INCIDENT {
+		return notifier.Event{}, false
+	}
+
+	probeHealth.Status = HEALTHY
+	event := probeHealth.newEvent(notifier.EventRecovery, probe, Result{})
+	event.Message = reason
+	event.RequestURL = probeHealth.IncidentURL
+	return event, true
+}
+
+// routeAlert returns the notifications of the triggered alert, or else of the probe
+func routeAlert(probe loader.ConfigProbe, reason ProbeStatusReason) []string {
+	if len(reason.Notifications) > 0 {
+		return reason.Notifications
+	}
+	return probe.Notifications
+}
+
+// mergeNotifications returns the notifications of both lists, an empty list
+// stands for all the notifications
+func mergeNotifications(notifications []string, others []string) []string {
+	if len(notifications) == 0 || len(others) == 0 {
+		return nil
+	}
+
+	merged := slices.Clone(notifications)
+	for _, id := range others {
+		if !slices.Contains(merged, id) {
+			merged = append(merged, id)
+		}
+	}
+	return merged
+}
+
+// newEvent creates a notification event from the current probe health and the
+// last check of the probe run, which is the failing one in case of an incident
+func (probeHealth *ProbeHealth) newEvent(eventType notifier.EventType, probe loader.ConfigProbe, result Result) notifier.Event {
+	event := notifier.Event{
+		Type:       eventType,
+		ProbeID:    probe.ID,
+		ProbeName:  probe.Name,
+		Status:     string(probeHealth.Status),
+		RequestURL: result.Reason.RequestURL,
+		StartedAt:  probeHealth.IncidentStartedAt,
+		Timestamp:  time.Now(),
+	}
+
+	if len(result.Checks) > 0 {
+		check := result.Checks[len(result.Checks)-1]
+		event.ResponseStatus = check.StatusCode
+		event.ResponseTime = check.ResponseTime
+		if event.RequestURL == "" {
+			event.RequestURL = check.URL
+		}
+	}
+
+	return event
+}
@@ -0,0 +1,328 @@
+package probers
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"hyperjumptech/monika/internal/loader"
+	notifier "hyperjumptech/monika/internal/notification"
+)
+
+// recorder is a notifier recording the events it is sent
+type recorder struct {
+	mu     sync.Mutex
+	events []notifier.Event
+}
+
+func (r *recorder) Send(ctx context.Context, event notifier.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+// failure returns the result of a probe run that triggered the alert on the URL
+func failure(query string, url string, notifications ...string) Result {
+	return Result{
+		Failed: true,
+		Reason: ProbeStatusReason{AlertQuery: query, AlertMessage: query + " is triggered", RequestURL: url, Notifications: notifications},
+		Checks: []Check{{Target: "GET " + url, URL: url, StatusCode: 500}},
+	}
+}
+
+// success returns the result of a probe run where every check passed
+func success(url string) Result {
+	return Result{Checks: []Check{{Target: "GET " + url, URL: url, StatusCode: 200}}}
+}
+
+// testProbe returns a probe with a single request and the given thresholds
+func testProbe(incidentThreshold int, recoveryThreshold int) loader.ConfigProbe {
+	return loader.ConfigProbe{
+		ID:            "probe",
+		Name:          "Probe",
+		Notifications: []string{"slack"},
+		Requests: []loader.ConfigProbeRequest{{
+			URL:               "https://example.com",
+			IncidentThreshold: incidentThreshold,
+			RecoveryThreshold: recoveryThreshold,
+		}},
+	}
+}
+
+// step is a probe run and the event it is expected to notify, if any
+type step struct {
+	result Result
+	// event is the type of the notified event, empty if none is expected
+	event notifier.EventType
+	// notifications are the notifications expected to receive the event
+	notifications []string
+}
+
+func TestUpdate(t *testing.T) {
+	const url = "https://example.com"
+	const otherURL = "https://example.com/other"
+
+	tests := []struct {
+		name              string
+		incidentThreshold int
+		recoveryThreshold int
+		steps             []step
+	}{
+		{
+			name:              "incident once the incident threshold is reached",
+			incidentThreshold: 3,
+			recoveryThreshold: 1,
+			steps: []step{
+				{result: failure("status == 500", url)},
+				{result: failure("status == 500", url)},
+				{result: failure("status == 500", url), event: notifier.EventIncident, notifications: []string{"slack"}},
+				{result: failure("status == 500", url)},
+			},
+		},
+		{
+			name:              "a success resets the incident count",
+			incidentThreshold: 2,
+			recoveryThreshold: 1,
+			steps: []step{
+				{result: failure("status == 500", url)},
+				{result: success(url)},
+				{result: failure("status == 500", url)},
+				{result: failure("status == 500", url), event: notifier.EventIncident, notifications: []string{"slack"}},
+			},
+		},
+		{
+			name:              "recovery once the recovery threshold is reached",
+			incidentThreshold: 1,
+			recoveryThreshold: 2,
+			steps: []step{
+				{result: failure("status == 500", url), event: notifier.EventIncident, notifications: []string{"slack"}},
+				{result: success(url)},
+				{result: success(url), event: notifier.EventRecovery, notifications: []string{"slack"}},
+				{result: success(url)},
+			},
+		},
+		{
+			name:              "a failure resets the recovery count",
+			incidentThreshold: 1,
+			recoveryThreshold: 2,
+			steps: []step{
+				{result: failure("status == 500", url), event: notifier.EventIncident, notifications: []string{"slack"}},
+				{result: success(url)},
+				{result: failure("status == 500", url)},
+				{result: success(url)},
+				{result: success(url), event: notifier.EventRecovery, notifications: []string{"slack"}},
+			},
+		},
+		{
+			name:              "a healthy probe does not recover",
+			incidentThreshold: 1,
+			recoveryThreshold: 1,
+			steps: []step{
+				{result: success(url)},
+				{result: success(url)},
+			},
+		},
+		{
+			name:              "incident update once per other alert, its notifications receive the recovery",
+			incidentThreshold: 1,
+			recoveryThreshold: 1,
+			steps: []step{
+				{result: failure("status == 500", url), event: notifier.EventIncident, notifications: []string{"slack"}},
+				{result: failure("status == 500", url)},
+				{result: failure("response_time > 1000", otherURL, "pagerduty"), event: notifier.EventIncidentUpdate, notifications: []string{"pagerduty"}},
+				{result: failure("response_time > 1000", otherURL, "pagerduty")},
+				{result: success(url), event: notifier.EventRecovery, notifications: []string{"slack", "pagerduty"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			probe := testProbe(test.incidentThreshold, test.recoveryThreshold)
+			probeHealth := NewProbeHealth(probe)
+
+			for i, step := range test.steps {
+				event, notifications, changed := probeHealth.update(probe, step.result)
+				if step.event == "" {
+					if changed {
+						t.Fatalf("step %d: got %s event, want none", i, event.Type)
+					}
+					continue
+				}
+
+				if !changed {
+					t.Fatalf("step %d: got no event, want %s", i, step.event)
+				}
+				if event.Type != step.event {
+					t.Fatalf("step %d: got %s event, want %s", i, event.Type, step.event)
+				}
+				if !slices.Equal(notifications, step.notifications) {
+					t.Fatalf("step %d: got notifications %v, want %v", i, notifications, step.notifications)
+				}
+
+				// The update and the recovery refer to the URL of the incident
+				switch event.Type {
+				case notifier.EventIncidentUpdate:
+					if event.IncidentURL != url {
+						t.Errorf("step %d: got incident URL %q, want %q", i, event.IncidentURL, url)
+					}
+				case notifier.EventRecovery:
+					if event.RequestURL != url {
+						t.Errorf("step %d: got request URL %q, want %q", i, event.RequestURL, url)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	const url = "https://example.com"
+
+	tests := []struct {
+		name string
+		// previous are the runs of the probe before the configuration is reloaded
+		previous []Result
+		// steps are the runs of the probe after the configuration is reloaded
+		steps []step
+	}{
+		{
+			name:     "the ongoing incident is resolved after the reload",
+			previous: []Result{failure("status == 500", url)},
+			steps: []step{
+				{result: failure("status == 500", url)},
+				{result: success(url), event: notifier.EventRecovery, notifications: []string{"slack"}},
+			},
+		},
+		{
+			name:     "the alerts of the ongoing incident are not notified again",
+			previous: []Result{failure("status == 500", url), failure("response_time > 1000", url, "pagerduty")},
+			steps: []step{
+				{result: failure("status == 500", url)},
+				{result: failure("response_time > 1000", url, "pagerduty")},
+				{result: success(url), event: notifier.EventRecovery, notifications: []string{"slack", "pagerduty"}},
+			},
+		},
+		{
+			name:     "a healthy probe starts over",
+			previous: []Result{success(url)},
+			steps: []step{
+				{result: success(url)},
+				{result: failure("status == 500", url), event: notifier.EventIncident, notifications: []string{"slack"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previousProbe := testProbe(1, 1)
+			previous := NewProbeHealth(previousProbe)
+			for _, result := range test.previous {
+				previous.update(previousProbe, result)
+			}
+
+			// The reload changes the probe, which is restarted with a new health state
+			probe := previousProbe
+			probe.Name = "Renamed probe"
+			probeHealth := NewProbeHealth(probe)
+			probeHealth.resume(previous)
+
+			for i, step := range test.steps {
+				event, notifications, changed := probeHealth.update(probe, step.result)
+				if step.event == "" {
+					if changed {
+						t.Fatalf("step %d: got %s event, want none", i, event.Type)
+					}
+					continue
+				}
+
+				if !changed || event.Type != step.event {
+					t.Fatalf("step %d: got %s event (notified: %t), want %s", i, event.Type, changed, step.event)
+				}
+				if !slices.Equal(notifications, step.notifications) {
+					t.Fatalf("step %d: got notifications %v, want %v", i, notifications, step.notifications)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyResolvesStoppedProbes(t *testing.T) {
+	const url = "https://example.com"
+
+	tests := []struct {
+		name string
+		// previous are the runs of the probe before the configuration is reloaded
+		previous []Result
+		// configured are the probes of the reloaded configuration
+		configured func(probe loader.ConfigProbe) []loader.ConfigProbe
+		// resolved reports whether a recovery is expected
+		resolved bool
+	}{
+		{
+			name:       "removed probe in incident",
+			previous:   []Result{failure("status == 500", url)},
+			configured: func(probe loader.ConfigProbe) []loader.ConfigProbe { return nil },
+			resolved:   true,
+		},
+		{
+			name:       "removed healthy probe",
+			previous:   []Result{success(url)},
+			configured: func(probe loader.ConfigProbe) []loader.ConfigProbe { return nil },
+		},
+		{
+			name:     "changed probe in incident that cannot be restarted",
+			previous: []Result{failure("status == 500", url)},
+			configured: func(probe loader.ConfigProbe) []loader.ConfigProbe {
+				// No prober is registered by this package, so the changed probe cannot be started
+				probe.Name = "Renamed probe"
+				return []loader.ConfigProbe{probe}
+			},
+			resolved: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slack := &recorder{}
+			channels := notifier.Channels{{ID: "slack", Type: "slack", Notifier: slack}}
+
+			probe := testProbe(1, 1)
+			health := NewProbeHealth(probe)
+			for _, result := range test.previous {
+				health.update(probe, result)
+			}
+
+			// The probe is running, its goroutine has already exited
+			done := make(chan struct{})
+			close(done)
+			manager := NewManager()
+			manager.channels = channels
+			manager.probes[probe.ID] = &runningProbe{probe: probe, health: health, cancel: func() {}, done: done}
+
+			manager.Apply(&loader.Config{Probes: test.configured(probe)}, channels)
+			defer manager.Stop()
+
+			if _, running := manager.probes[probe.ID]; running {
+				t.Errorf("probe %s is still running", probe.ID)
+			}
+			if !test.resolved {
+				if len(slack.events) > 0 {
+					t.Fatalf("got %d events, want none", len(slack.events))
+				}
+				return
+			}
+
+			if len(slack.events) != 1 {
+				t.Fatalf("got %d events, want 1", len(slack.events))
+			}
+			event := slack.events[0]
+			if event.Type != notifier.EventRecovery || event.ProbeID != probe.ID || event.RequestURL != url {
+				t.Errorf("got %s event of %s for %q, want recovery of %s for %q", event.Type, event.ProbeID, event.RequestURL, probe.ID, url)
+			}
+		})
+	}
+}
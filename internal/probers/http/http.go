@@ -1,33 +1,26 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
-	assertion "hyperjumptech/monika/internal/assertion"
 	"hyperjumptech/monika/internal/loader"
-	"hyperjumptech/monika/internal/logger"
-	notifier "hyperjumptech/monika/internal/notification"
+	"hyperjumptech/monika/internal/probers"
 )
 
-// ProbeStatus represents the status of a probe
-type ProbeStatus string
-
-const (
-	HEALTHY  ProbeStatus = "Healthy"
-	INCIDENT ProbeStatus = "Incident"
-)
-
-// Probehealth holds the current status of a probe and its thresholds
-type ProbeHealth struct {
-	Status            ProbeStatus
-	IncidentCount     int
-	RecoveryCount     int
-	RecoveryThreshold int
-	IncidentThreshold int
+func init() {
+	probers.Register("http", New)
 }
 
 // HttpResult represents the result of an HTTP request
@@ -39,202 +32,145 @@ type HttpResult struct {
 	Size         int
 }
 
-// ProbeStatusReason represents the reason for a probe's status change
-type ProbeStatusReason struct {
-	AlertQuery   string
-	AlertMessage string
-	RequestURL   string
+// Prober sends the requests of an HTTP probe
+type Prober struct {
+	probe loader.ConfigProbe
 }
 
-func CreateProbes(config *loader.Config) {
-	logger := logger.GetLogger()
+// New creates the prober of an HTTP probe
+func New(probe loader.ConfigProbe) (probers.Prober, error) {
+	if len(probe.Requests) == 0 {
+		return nil, errors.New("HTTP probe " + probe.ID + " has no requests")
+	}
 
-	for _, probe := range config.Probes {
-		// Determine the largest recovery and incident thresholds
-		recoveryThreshold := probe.Requests[0].RecoveryThreshold
-		incidentThreshold := probe.Requests[0].IncidentThreshold
+	return &Prober{probe: probe}, nil
+}
 
-		for _, request := range probe.Requests {
-			if request.RecoveryThreshold > recoveryThreshold {
-				recoveryThreshold = request.RecoveryThreshold
-			}
+// Probe sends the requests of the probe once and evaluates their alerts
+func (p *Prober) Probe(ctx context.Context) probers.Result {
+	result := probers.Result{
+		Checks: make([]probers.Check, 0, len(p.probe.Requests)),
+	}
 
-			if request.IncidentThreshold > incidentThreshold {
-				incidentThreshold = request.IncidentThreshold
+	// Responses of the previous requests, used to render the next requests
+	responses := make([]map[string]interface{}, 0, len(p.probe.Requests))
+
+	// Make HTTP request to the URL
+	for _, request := range p.probe.Requests {
+		target := request.Method + " " + request.URL
+
+		// Render the request using the previous responses
+		rendered, err := renderRequest(request, responses)
+		if err != nil {
+			result.Failed = true
+			result.Reason = probers.ProbeStatusReason{
+				AlertQuery:   "error != nil",
+				AlertMessage: err.Error(),
+				RequestURL:   request.URL,
 			}
+			result.Checks = append(result.Checks, probers.Check{Target: target, URL: request.URL, Error: err})
+			break
 		}
-
-		probeHealth := &ProbeHealth{
-			Status:            HEALTHY,
-			RecoveryThreshold: recoveryThreshold,
-			IncidentThreshold: incidentThreshold,
+		request = rendered
+		target = request.Method + " " + request.URL
+
+		// Send the request
+		resp, err := sendRequest(ctx, request, time.Duration(request.Timeout)*time.Millisecond)
+
+		// If error, mark as failed
+		if err != nil {
+			result.Failed = true
+			result.Reason = probers.ProbeStatusReason{
+				AlertQuery:   "error != nil",
+				AlertMessage: err.Error(),
+				RequestURL:   request.URL,
+			}
+			result.Checks = append(result.Checks, probers.Check{Target: target, URL: request.URL, Error: err})
+			break
 		}
 
-		// Run probe using goroutine
-		go func(probe loader.ConfigProbe, probeHealth *ProbeHealth) {
-			interval := time.Duration(probe.Interval) * time.Second
-			timeout := time.Duration(probe.Requests[0].Timeout) * time.Millisecond
-
-			// Start the check for each request
-			for {
-				time.Sleep(interval)
-
-				var reason ProbeStatusReason
-				failed := false
-
-				// Make HTTP request to the URL
-				for _, request := range probe.Requests {
-					// Send the request
-					resp, err := sendRequest(request, timeout)
-
-					// If error, mark as failed
-					if err != nil {
-						reason = ProbeStatusReason{
-							AlertQuery:   "error != nil",
-							AlertMessage: err.Error(),
-							RequestURL:   request.URL,
-						}
-						failed = true
-
-						// Log error without trying to access resp fields
-						logger.Info().Str("context", "probe").Str("type", "http").Msgf("%s - %s - %s - %s - Error: %s",
-							probe.Name, probeHealth.Status, request.Method, request.URL, err.Error())
-
-						break
-					}
-
-					logger.Info().Str("context", "probe").Str("type", "http").Msgf("%s - %s - %s - %s - %d - %.3fms", probe.Name, probeHealth.Status, request.Method, request.URL, resp.StatusCode, resp.ResponseTime)
-
-					// If response time is greater than the timeout, mark as failed
-					if resp.ResponseTime > float64(request.Timeout) {
-						reason = ProbeStatusReason{
-							AlertQuery:   fmt.Sprintf("response.time > %d", request.Timeout),
-							AlertMessage: "Request timed out",
-							RequestURL:   request.URL,
-						}
-						failed = true
-						break
-					}
-
-					// Evaluate alert query expressions from the config file
-					for _, alert := range request.Alerts {
-						alertTriggered := assertion.Evaluate(alert.Query, map[string]interface{}{
-							"response": map[string]interface{}{
-								"status":  resp.StatusCode,
-								"time":    resp.ResponseTime,
-								"body":    resp.Body,
-								"headers": resp.Headers,
-								"size":    resp.Size,
-							},
-						})
-
-						// Alert condition met, take action
-						if alertTriggered {
-							// Store the reason for the incident
-							reason = ProbeStatusReason{
-								AlertQuery:   alert.Query,
-								AlertMessage: alert.Message,
-								RequestURL:   request.URL,
-							}
-
-							failed = true
-							break
-						}
-					}
-				}
-
-				// Handle requests results
-				if failed {
-					// If any request failed, handle incident detection
-					// Add the incident count and reset the recovery count
-					probeHealth.IncidentCount++
-					probeHealth.RecoveryCount = 0
-
-					// If the probe is in healthy state, check if it has reached the incident threshold
-					if probeHealth.Status == HEALTHY {
-						// If the incident count is greater than or equal to the incident threshold, mark the probe as incident
-						if probeHealth.IncidentCount >= probeHealth.IncidentThreshold {
-							probeHealth.Status = INCIDENT
-
-							// Construct a more detailed notification message
-							notificationMsg := fmt.Sprintf(
-								"Probe is now in an incident state\n\n"+
-									"Probe: %s\n"+
-									"Alert: %s\n"+
-									"Message: %s\n"+
-									"URL: %s",
-								probe.Name,
-								reason.AlertQuery,
-								reason.AlertMessage,
-								reason.RequestURL,
-							)
-
-							// Send notification to the configured channel(s)
-							logger.Info().Str("context", "probe").Str("type", "http").Msgf("Probe %s is unhealthy, sending notification to the configured channel(s)", probe.Name)
-							for _, notification := range config.Notifications {
-								notifier.SendNotification(notification, notificationMsg)
-							}
-						} else {
-							// Else, just log
-							logger.Info().Str("context", "probe").Str("type", "http").Msgf("Alert detected for probe %s: %s. Attempt %d of %d until it may be considered an incident", probe.Name, reason.AlertMessage, probeHealth.IncidentCount, probeHealth.IncidentThreshold)
-						}
-					}
-				} else {
-					// If all request were successful, handle recovery detection
-					// Add the recovery count and reset the incident count
-					probeHealth.RecoveryCount++
-					probeHealth.IncidentCount = 0
-
-					// If the probe is in incident state, check if it has reached the recovery threshold
-					if probeHealth.Status == INCIDENT {
-						// If the incident count is greater than or equal to the recover threshold, mark the probe as healthy
-						if probeHealth.RecoveryCount >= probeHealth.RecoveryThreshold {
-							probeHealth.Status = HEALTHY
-
-							// Construct a more detailed notification message
-							notificationMsg := fmt.Sprintf(
-								"Probe is now in a healthy state\n\n"+
-									"Probe: %s\n"+
-									"All checks passed successfully for %d consecutive attempts",
-								probe.Name,
-								probeHealth.RecoveryThreshold,
-							)
-
-							// Send notification to the configured channel(s)
-							logger.Info().Str("context", "probe").Str("type", "http").Msgf("Probe %s is healthy, sending notification to the configured channel(s)", probe.Name)
-							for _, notification := range config.Notifications {
-								notifier.SendNotification(notification, notificationMsg)
-							}
-						} else {
-							// Else, just log
-							logger.Info().Str("context", "probe").Str("type", "http").Msgf("Alert has been resolved for probe %s: %s. Attempt %d of %d until it may be considered a recovery", probe.Name, reason.AlertMessage, probeHealth.RecoveryCount, probeHealth.RecoveryThreshold)
-						}
-					}
-				}
+		environment := map[string]interface{}{
+			"response": map[string]interface{}{
+				"status":  resp.StatusCode,
+				"time":    resp.ResponseTime,
+				"body":    resp.Body,
+				"headers": resp.Headers,
+				"size":    resp.Size,
+			},
+		}
+		result.Checks = append(result.Checks, probers.Check{
+			Target:       target,
+			URL:          request.URL,
+			Summary:      fmt.Sprintf("%d - %.3fms", resp.StatusCode, resp.ResponseTime),
+			StatusCode:   resp.StatusCode,
+			ResponseTime: resp.ResponseTime,
+			Environment:  environment,
+		})
+		responses = append(responses, responseContext(resp))
+
+		// If response time is greater than the timeout, mark as failed
+		if resp.ResponseTime > float64(request.Timeout) {
+			result.Failed = true
+			result.Reason = probers.ProbeStatusReason{
+				AlertQuery:   fmt.Sprintf("response.time > %d", request.Timeout),
+				AlertMessage: "Request timed out",
+				RequestURL:   request.URL,
 			}
-		}(probe, probeHealth)
+			break
+		}
+
+		// Evaluate alert query expressions from the config file
+		// and stop the chain, the next requests may depend on this response
+		if reason, triggered := probers.EvaluateAlerts(request.Alerts, environment, request.URL); triggered {
+			result.Failed = true
+			result.Reason = reason
+			break
+		}
 	}
+
+	return result
 }
 
-func sendRequest(request loader.ConfigProbeRequest, timeout time.Duration) (*HttpResult, error) {
+func sendRequest(ctx context.Context, request loader.ConfigProbeRequest, timeout time.Duration) (*HttpResult, error) {
 	// Create a new HTTP Client
 	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Millisecond,
+		Timeout: timeout,
+	}
+	if request.AllowUnauthorized {
+		// Skip the certificate validation, e.g. for self-signed certificates
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = transport
 	}
 	defer client.CloseIdleConnections()
 
+	// Encode the request body based on its Content-Type header
+	body, contentType, err := encodeBody(request.Body, getHeader(request.Headers, "Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a new HTTP request
 	start := time.Now()
 	method := strings.ToUpper(request.Method)
-	req, err := http.NewRequest(method, request.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, method, request.URL, body)
 	if err != nil {
 		return nil, err
 	}
 
+	// Set the request headers
+	for key, value := range request.Headers {
+		req.Header.Set(key, value)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	// Read the response body
 	bodyBytes, err := io.ReadAll(resp.Body)
@@ -259,3 +195,72 @@ func sendRequest(request loader.ConfigProbeRequest, timeout time.Duration) (*Htt
 		Size:         len(bodyBytes), // More accurate than ContentLength which can be -1
 	}, nil
 }
+
+// getHeader returns the value of a header using a case-insensitive lookup
+func getHeader(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// encodeBody encodes the request body according to the given Content-Type.
+// It returns the encoded body and the Content-Type that should be sent with it.
+func encodeBody(body interface{}, contentType string) (io.Reader, string, error) {
+	if body == nil {
+		return nil, contentType, nil
+	}
+
+	// Plain strings are sent as-is, e.g. text or XML bodies
+	if raw, ok := body.(string); ok {
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+		return strings.NewReader(raw), contentType, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("form-urlencoded body must be a map, got %T", body)
+		}
+
+		form := url.Values{}
+		for key, value := range fields {
+			form.Set(key, fmt.Sprint(value))
+		}
+		return strings.NewReader(form.Encode()), contentType, nil
+	case "multipart/form-data":
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("multipart body must be a map, got %T", body)
+		}
+
+		// The boundary is generated by the writer, so the Content-Type is replaced
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+		for key, value := range fields {
+			if err := writer.WriteField(key, fmt.Sprint(value)); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", err
+		}
+		return buffer, writer.FormDataContentType(), nil
+	default:
+		// Anything else is encoded as JSON
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", err
+		}
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		return bytes.NewReader(encoded), contentType, nil
+	}
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	assertion "hyperjumptech/monika/internal/assertion"
+	"hyperjumptech/monika/internal/loader"
+)
+
+// templatePattern matches template expressions such as {{ responses[0].body.token }}
+var templatePattern = regexp.MustCompile(`{{\s*(.+?)\s*}}`)
+
+// responseContext converts a request result into the value exposed to templates
+// of the following requests as responses[index]
+func responseContext(resp *HttpResult) map[string]interface{} {
+	// Expose JSON bodies as objects so their fields can be accessed directly
+	var body interface{} = resp.Body
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &decoded); err == nil {
+		body = decoded
+	}
+
+	return map[string]interface{}{
+		"status":  resp.StatusCode,
+		"time":    resp.ResponseTime,
+		"body":    body,
+		"headers": resp.Headers,
+		"size":    resp.Size,
+	}
+}
+
+// renderRequest interpolates the URL, headers and body of a request using the
+// responses of the previous requests in the same probe
+func renderRequest(request loader.ConfigProbeRequest, responses []map[string]interface{}) (loader.ConfigProbeRequest, error) {
+	data := map[string]interface{}{
+		"responses": responses,
+	}
+
+	rendered := request
+	url, err := renderString(request.URL, data)
+	if err != nil {
+		return request, err
+	}
+	rendered.URL = url
+
+	rendered.Headers = make(map[string]string, len(request.Headers))
+	for key, value := range request.Headers {
+		renderedValue, err := renderString(value, data)
+		if err != nil {
+			return request, err
+		}
+		rendered.Headers[key] = renderedValue
+	}
+
+	body, err := renderValue(request.Body, data)
+	if err != nil {
+		return request, err
+	}
+	rendered.Body = body
+
+	return rendered, nil
+}
+
+// renderValue renders every string found in a body value, including nested maps and lists
+func renderValue(value interface{}, data map[string]interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case string:
+		return renderString(typed, data)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			renderedItem, err := renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = renderedItem
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(typed))
+		for index, item := range typed {
+			renderedItem, err := renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			result[index] = renderedItem
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// renderString replaces every template expression in a string with its evaluated value
+func renderString(value string, data map[string]interface{}) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	var renderErr error
+	result := templatePattern.ReplaceAllStringFunc(value, func(match string) string {
+		expression := templatePattern.FindStringSubmatch(match)[1]
+		computed, err := assertion.Compute(expression, data)
+		if err != nil {
+			if renderErr == nil {
+				renderErr = fmt.Errorf("failed to render template %q: %w", match, err)
+			}
+			return match
+		}
+
+		return stringify(computed)
+	})
+
+	return result, renderErr
+}
+
+// stringify converts a computed template value into its string representation
+func stringify(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case float64:
+		// JSON numbers are decoded as float64, write ids such as 1234567 in full
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(typed)
+		if err != nil {
+			return fmt.Sprint(typed)
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprint(typed)
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"testing"
+
+	"hyperjumptech/monika/internal/loader"
+)
+
+func TestRenderRequest(t *testing.T) {
+	login := responseContext(&HttpResult{
+		StatusCode: 200,
+		Body:       `{"id": 1234567, "token": "secret", "balance": 0.25, "roles": ["admin"]}`,
+	})
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{name: "string field", template: "Bearer {{ responses[0].body.token }}", want: "Bearer secret"},
+		{name: "integer above 1e6", template: "/users/{{ responses[0].body.id }}", want: "/users/1234567"},
+		{name: "fractional number", template: "{{ responses[0].body.balance }}", want: "0.25"},
+		{name: "list", template: "{{ responses[0].body.roles }}", want: `["admin"]`},
+		{name: "status", template: "{{ responses[0].status }}", want: "200"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := loader.ConfigProbeRequest{
+				URL:     "https://example.com" + test.template,
+				Headers: map[string]string{"X-Value": test.template},
+				Body:    map[string]interface{}{"value": test.template},
+			}
+
+			rendered, err := renderRequest(request, []map[string]interface{}{login})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rendered.URL != "https://example.com"+test.want {
+				t.Errorf("got URL %q, want %q", rendered.URL, "https://example.com"+test.want)
+			}
+			if rendered.Headers["X-Value"] != test.want {
+				t.Errorf("got header %q, want %q", rendered.Headers["X-Value"], test.want)
+			}
+			if body := rendered.Body.(map[string]interface{}); body["value"] != test.want {
+				t.Errorf("got body value %q, want %q", body["value"], test.want)
+			}
+		})
+	}
+}
@@ -1,30 +1,19 @@
 package ping
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"hyperjumptech/monika/internal/loader"
-	"hyperjumptech/monika/internal/logger"
-	notifier "hyperjumptech/monika/internal/notification"
+	"hyperjumptech/monika/internal/probers"
 
 	probing "github.com/prometheus-community/pro-bing"
 )
 
-// ProbeStatus represents the status of a probe
-type ProbeStatus string
-
-const (
-	HEALTHY  ProbeStatus = "Healthy"
-	INCIDENT ProbeStatus = "Incident"
-)
-
-// Probehealth holds the current status of a probe and its thresholds
-type ProbeHealth struct {
-	Status            ProbeStatus
-	IncidentCount     int
-	RecoveryCount     int
-	RecoveryThreshold int
-	IncidentThreshold int
+func init() {
+	probers.Register("ping", New)
 }
 
 type PingResult struct {
@@ -32,99 +21,65 @@ type PingResult struct {
 	ResponseTime float64
 }
 
-func CreateProbes(config *loader.Config) {
-	logger := logger.GetLogger()
+// Prober pings the URI of a ping probe
+type Prober struct {
+	probe loader.ConfigProbe
+}
+
+// New creates the prober of a ping probe
+func New(probe loader.ConfigProbe) (probers.Prober, error) {
+	if probe.Ping.Uri == "" {
+		return nil, errors.New("ping probe " + probe.ID + " has no URI")
+	}
+
+	return &Prober{probe: probe}, nil
+}
+
+// Probe pings the probe URI once and evaluates its alerts
+func (p *Prober) Probe(ctx context.Context) probers.Result {
+	target := "PING " + p.probe.Ping.Uri
 
-	for _, probe := range config.Probes {
-		probeHealth := &ProbeHealth{
-			Status:            HEALTHY,
-			RecoveryThreshold: 5,
-			IncidentThreshold: 5,
+	// Send the request
+	resp, err := sendPing(ctx, p.probe.Ping)
+	if err != nil {
+		// If error, mark as failed
+		return probers.Result{
+			Failed: true,
+			Reason: probers.ProbeStatusReason{
+				AlertQuery:   "error != nil",
+				AlertMessage: err.Error(),
+				RequestURL:   p.probe.Ping.Uri,
+			},
+			Checks: []probers.Check{{Target: target, URL: p.probe.Ping.Uri, Error: err}},
 		}
+	}
+
+	environment := map[string]interface{}{
+		"response": map[string]interface{}{
+			"time": resp.ResponseTime,
+		},
+	}
+	result := probers.Result{
+		Checks: []probers.Check{{
+			Target:       target,
+			URL:          p.probe.Ping.Uri,
+			Summary:      fmt.Sprintf("%d - %.3fms", resp.StatusCode, resp.ResponseTime),
+			StatusCode:   resp.StatusCode,
+			ResponseTime: resp.ResponseTime,
+			Environment:  environment,
+		}},
+	}
 
-		// Run probe using goroutine
-		go func(probe loader.ConfigProbe, probeHealth *ProbeHealth) {
-			interval := time.Duration(probe.Interval) * time.Second
-
-			// Start the check for each request
-			for {
-				time.Sleep(interval)
-
-				// Make ping request to the URL
-				failed := false
-				// Send the request
-				resp, err := sendPing(probe.Ping)
-				logger.Info().Str("context", "probe").Str("type", "ping").Msgf("%s - %s - %s - %d - %.3fms", probe.Name, probeHealth.Status, probe.Ping.Uri, resp.StatusCode, resp.ResponseTime)
-
-				if err != nil {
-					// If error, mark as failed
-					failed = true
-					break
-				} else if resp.ResponseTime > float64(10_000) {
-					// If response time is greater than the 10 seconds timeout, mark as failed
-					failed = true
-					break
-				} else if resp.ResponseTime > 2000 {
-					// If response time is greater than 2 seconds, mark as failed
-					failed = true
-					break
-				} else {
-					// Otherwise, mark as successful
-					failed = false
-				}
-
-				// Handle requests results
-				if failed {
-					// If any request failed, handle incident detection
-					// Add the incident count and reset the recovery count
-					probeHealth.IncidentCount++
-					probeHealth.RecoveryCount = 0
-
-					// If the probe is in healthy state, check if it has reached the incident threshold
-					if probeHealth.Status == HEALTHY {
-						// If the incident count is greater than or equal to the incident threshold, mark the probe as incident
-						if probeHealth.IncidentCount >= probeHealth.IncidentThreshold {
-							probeHealth.Status = INCIDENT
-
-							// Send notification to the configured channel(s)
-							logger.Info().Str("context", "probe").Str("type", "ping").Msgf("Probe %s has failed, sending notification to the configured channel(s)", probe.Name)
-							for _, notification := range config.Notifications {
-								notifier.SendNotification(notification, "Probe "+probe.Name+" is now in an incident state")
-							}
-						} else {
-							// Else, just log
-							logger.Info().Str("context", "probe").Str("type", "ping").Msgf("Probe %s is failing, attempt %d of %d until it may be considered an incident", probe.Name, probeHealth.IncidentCount, probeHealth.IncidentThreshold)
-						}
-					}
-				} else {
-					// If all request were successful, handle recovery detection
-					// Add the recovery count and reset the incident count
-					probeHealth.RecoveryCount++
-					probeHealth.IncidentCount = 0
-
-					// If the probe is in incident state, check if it has reached the recovery threshold
-					if probeHealth.Status == INCIDENT {
-						// If the incident count is greater than or equal to the recover threshold, mark the probe as healthy
-						if probeHealth.RecoveryCount >= probeHealth.RecoveryThreshold {
-							probeHealth.Status = HEALTHY
-
-							// Send notification to the configured channel(s)
-							logger.Info().Str("context", "probe").Str("type", "ping").Msgf("Probe %s has recovered, sending notification to the configured channel(s)", probe.Name)
-							for _, notification := range config.Notifications {
-								notifier.SendNotification(notification, "Probe "+probe.Name+" is now in an healthy state")
-							}
-						} else {
-							// Else, just log
-							logger.Info().Str("context", "probe").Str("type", "ping").Msgf("Probe %s is recovering, attempt %d of %d until it may be considered a recovery", probe.Name, probeHealth.RecoveryCount, probeHealth.RecoveryThreshold)
-						}
-					}
-				}
-			}
-		}(probe, probeHealth)
+	// Evaluate alert query expressions from the config file
+	if reason, triggered := probers.EvaluateAlerts(p.probe.Ping.Alerts, environment, p.probe.Ping.Uri); triggered {
+		result.Failed = true
+		result.Reason = reason
 	}
+
+	return result
 }
 
-func sendPing(ping loader.ConfigProbePing) (*PingResult, error) {
+func sendPing(ctx context.Context, ping loader.ConfigProbePing) (*PingResult, error) {
 	// Create a new pinger
 	pinger, err := probing.NewPinger(ping.Uri)
 	if err != nil {
@@ -134,14 +89,17 @@ func sendPing(ping loader.ConfigProbePing) (*PingResult, error) {
 	pinger.Timeout = 10 * time.Second
 
 	// Run ping
-	err = pinger.Run()
+	err = pinger.RunWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get ping statistics
 	stats := pinger.Statistics()
-	responseTime := float64(stats.MaxRtt.Milliseconds()) / 1_000
+	if stats.PacketsRecv == 0 {
+		return nil, errors.New("no reply received within 10 seconds")
+	}
+	responseTime := float64(stats.MaxRtt.Microseconds()) / 1_000
 
 	return &PingResult{StatusCode: 200, ResponseTime: responseTime}, nil
 }
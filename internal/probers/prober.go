@@ -0,0 +1,144 @@
+package probers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	assertion "hyperjumptech/monika/internal/assertion"
+	"hyperjumptech/monika/internal/loader"
+)
+
+// Prober checks a probe's target(s) once, e.g. by sending its HTTP requests
+type Prober interface {
+	Probe(ctx context.Context) Result
+}
+
+// Factory creates the prober of a probe from its configuration
+type Factory func(probe loader.ConfigProbe) (Prober, error)
+
+// ProbeStatusReason represents the reason for a probe's status change
+type ProbeStatusReason struct {
+	AlertQuery   string
+	AlertMessage string
+	RequestURL   string
+	// Notifications are the notifications of the triggered alert, if it has any
+	Notifications []string
+}
+
+// Check holds the outcome of a single target of a probe, e.g. one HTTP request
+type Check struct {
+	// Target describes what was checked, e.g. "GET https://example.com"
+	Target string
+	// URL is the address that was checked, e.g. "https://example.com"
+	URL string
+	// Summary describes the response, e.g. "200 - 12.000ms"
+	Summary      string
+	StatusCode   int
+	ResponseTime float64
+	// Environment is the data the alert queries were evaluated against
+	Environment map[string]interface{}
+	Error       error
+}
+
+// Result holds the outcome of a single run of a probe
+type Result struct {
+	Failed bool
+	Reason ProbeStatusReason
+	Checks []Check
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes a prober available for the given probe type.
+// It is meant to be called from the init function of the prober package.
+func Register(probeType string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, exists := registry[probeType]; exists {
+		panic("probers: Register called twice for probe type " + probeType)
+	}
+	registry[probeType] = factory
+}
+
+// Types returns the registered probe types
+func Types() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for probeType := range registry {
+		types = append(types, probeType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates the prober registered for the type of the probe
+func New(probe loader.ConfigProbe) (Prober, error) {
+	registryMutex.RLock()
+	factory, exists := registry[probe.Type()]
+	registryMutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unsupported probe type: %s", probe.Type())
+	}
+	return factory(probe)
+}
+
+// EvaluateAlerts evaluates the alerts against the environment and returns the
+// reason of the first triggered alert, if any
+func EvaluateAlerts(alerts []loader.ConfigProbeRequestAlert, environment map[string]interface{}, target string) (ProbeStatusReason, bool) {
+	for _, alert := range alerts {
+		// Alert condition met, take action
+		if assertion.Evaluate(alert.Query, environment) {
+			return ProbeStatusReason{
+				AlertQuery:    alert.Query,
+				AlertMessage:  alert.Message,
+				RequestURL:    target,
+				Notifications: alert.Notifications,
+			}, true
+		}
+	}
+
+	return ProbeStatusReason{}, false
+}
+
+// CheckResult returns the result of a probe run made of a single check. The
+// probe fails on the first triggered alert, or else on the error of the check.
+func CheckResult(check Check, alerts []loader.ConfigProbeRequestAlert) Result {
+	result := Result{Checks: []Check{check}}
+
+	// Evaluate alert query expressions from the config file
+	if reason, triggered := EvaluateAlerts(alerts, check.Environment, check.URL); triggered {
+		result.Failed = true
+		result.Reason = reason
+		return result
+	}
+
+	// If error, mark as failed
+	if check.Error != nil {
+		result.Failed = true
+		result.Reason = ProbeStatusReason{
+			AlertQuery:   "error != nil",
+			AlertMessage: check.Error.Error(),
+			RequestURL:   check.URL,
+		}
+	}
+
+	return result
+}
+
+// ErrorMessage returns the message of the error, empty if there is none, so
+// that errors are available to the alerts as response.error
+func ErrorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
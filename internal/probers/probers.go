@@ -1,35 +1,169 @@
 package probers
 
 import (
+	"context"
+	"reflect"
+	"sync"
+
 	"hyperjumptech/monika/internal/loader"
-	HTTPProber "hyperjumptech/monika/internal/probers/http"
-	PingProber "hyperjumptech/monika/internal/probers/ping"
+	"hyperjumptech/monika/internal/logger"
+	notifier "hyperjumptech/monika/internal/notification"
 )
 
-func InitializeProbes(config *loader.Config) {
-	// Create probes based on type
-	HTTPProbes := make([]loader.ConfigProbe, 0)
-	PingProbes := make([]loader.ConfigProbe, 0)
+// Manager runs the probes of the loaded configuration and reconciles them when
+// the configuration is reloaded
+type Manager struct {
+	mu            sync.Mutex
+	probes        map[string]*runningProbe
+	notifications []loader.ConfigNotification
+	channels      notifier.Channels
+}
+
+// runningProbe holds a probe goroutine and the state needed to stop it
+type runningProbe struct {
+	probe  loader.ConfigProbe
+	health *ProbeHealth
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewManager creates a manager without any running probes
+func NewManager() *Manager {
+	return &Manager{
+		probes: make(map[string]*runningProbe),
+	}
+}
+
+// Apply reconciles the running probes with the given configuration.
+// Probes are matched by ID: removed probes are stopped and their incident is
+// resolved, changed probes are restarted with their incident, if any, and
+// unchanged probes keep running with their current health.
+func (m *Manager) Apply(config *loader.Config, channels notifier.Channels) {
+	logger := logger.GetLogger()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Probes hold the notifications they send to, so a change restarts every probe
+	notificationsChanged := !reflect.DeepEqual(m.notifications, config.Notifications)
+	previousChannels := m.channels
+	m.notifications = config.Notifications
+	m.channels = channels
 
-	// Filter probes based on type
+	// Stop the probes that no longer exist in the configuration
+	configured := make(map[string]bool, len(config.Probes))
 	for _, probe := range config.Probes {
-		if probe.Ping.Uri != "" {
-			PingProbes = append(PingProbes, probe)
-		} else {
-			HTTPProbes = append(HTTPProbes, probe)
+		configured[probe.ID] = true
+	}
+	for id, running := range m.probes {
+		if !configured[id] {
+			logger.Info().Str("context", "probe").Str("type", "manager").Msgf("Stopping removed probe %s", running.probe.Name)
+			running.stop()
+			m.forget(id, running, previousChannels, "The probe was removed from the configuration")
+		}
+	}
+
+	// Start new probes and restart the changed ones
+	for _, probe := range config.Probes {
+		running, exists := m.probes[probe.ID]
+		if !exists {
+			logger.Info().Str("context", "probe").Str("type", "manager").Msgf("Starting probe %s", probe.Name)
+			m.set(probe.ID, m.start(probe, nil))
+			continue
 		}
+
+		if !reflect.DeepEqual(running.probe, probe) {
+			logger.Info().Str("context", "probe").Str("type", "manager").Msgf("Restarting changed probe %s", probe.Name)
+			running.stop()
+			health := NewProbeHealth(probe)
+			health.resume(running.health)
+			m.restart(probe.ID, running, m.start(probe, health), previousChannels)
+			continue
+		}
+
+		if notificationsChanged {
+			// Keep the health state, only the notifications have changed
+			running.stop()
+			m.restart(probe.ID, running, m.start(probe, running.health), previousChannels)
+		}
+	}
+}
+
+// Stop stops every running probe
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, running := range m.probes {
+		running.stop()
+		delete(m.probes, id)
+	}
+}
+
+// start runs a probe in a goroutine, reusing the given health state if any.
+// It returns nil if the probe cannot be run.
+func (m *Manager) start(probe loader.ConfigProbe, health *ProbeHealth) *runningProbe {
+	logger := logger.GetLogger()
+
+	// Create the prober registered for the probe type
+	prober, err := New(probe)
+	if err != nil {
+		logger.Error().Err(err).Str("context", "probe").Str("type", "manager").Msgf("Failed to start probe %s", probe.Name)
+		return nil
+	}
+
+	if health == nil {
+		health = NewProbeHealth(probe)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &runningProbe{
+		probe:  probe,
+		health: health,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	channels := m.channels
+
+	// Run probe using goroutine
+	go func() {
+		defer close(running.done)
+		runProbe(ctx, probe, prober, channels, health)
+	}()
+
+	return running
+}
+
+// set stores a running probe, or forgets the probe if it could not be started
+func (m *Manager) set(id string, running *runningProbe) {
+	if running == nil {
+		delete(m.probes, id)
+		return
 	}
+	m.probes[id] = running
+}
 
-	// Create new configuration to pass to probes
-	HTTPConfig := loader.Config{
-		Probes:        HTTPProbes,
-		Notifications: config.Notifications,
+// restart replaces a stopped probe with its restarted version. If the probe
+// could not be restarted, it is forgotten and its incident is resolved.
+func (m *Manager) restart(id string, stopped *runningProbe, restarted *runningProbe, previousChannels notifier.Channels) {
+	if restarted == nil {
+		m.forget(id, stopped, previousChannels, "The probe could not be restarted after the configuration was reloaded")
+		return
 	}
-	HTTPProber.CreateProbes(&HTTPConfig)
+	m.probes[id] = restarted
+}
 
-	PingConfig := loader.Config{
-		Probes:        PingProbes,
-		Notifications: config.Notifications,
+// forget forgets a stopped probe and resolves its ongoing incident, if any,
+// through the channels the incident was sent to
+func (m *Manager) forget(id string, stopped *runningProbe, channels notifier.Channels, reason string) {
+	delete(m.probes, id)
+	if event, resolved := stopped.health.resolve(stopped.probe, reason); resolved {
+		channels.Select(stopped.health.Notifications).Send(context.Background(), event)
 	}
-	PingProber.CreateProbes(&PingConfig)
+}
+
+// stop cancels the probe and waits until its goroutine has exited
+func (r *runningProbe) stop() {
+	r.cancel()
+	<-r.done
 }
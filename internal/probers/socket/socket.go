@@ -0,0 +1,148 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"hyperjumptech/monika/internal/loader"
+	"hyperjumptech/monika/internal/probers"
+)
+
+func init() {
+	probers.Register("socket", New)
+}
+
+// maxResponseSize is the most data read from the service
+const maxResponseSize = 64 * 1024
+
+// SocketResult holds the response of a TCP service
+type SocketResult struct {
+	Data         string
+	ResponseTime float64
+}
+
+// Prober connects to the TCP service of a socket probe
+type Prober struct {
+	probe loader.ConfigProbe
+}
+
+// New creates the prober of a socket probe
+func New(probe loader.ConfigProbe) (probers.Prober, error) {
+	if probe.Socket.Host == "" {
+		return nil, errors.New("socket probe " + probe.ID + " has no host")
+	}
+
+	return &Prober{probe: probe}, nil
+}
+
+// Probe connects to the service once, sends the data and evaluates its alerts
+func (p *Prober) Probe(ctx context.Context) probers.Result {
+	address := net.JoinHostPort(p.probe.Socket.Host, strconv.Itoa(p.probe.Socket.Port))
+	target := "TCP " + address
+
+	// Connection errors are available to the alerts as response.error
+	resp, err := sendSocket(ctx, p.probe.Socket)
+	environment := map[string]interface{}{
+		"response": map[string]interface{}{
+			"time":  resp.ResponseTime,
+			"data":  resp.Data,
+			"size":  len(resp.Data),
+			"error": probers.ErrorMessage(err),
+		},
+	}
+	check := probers.Check{
+		Target:       target,
+		URL:          address,
+		Summary:      fmt.Sprintf("%d bytes - %.3fms", len(resp.Data), resp.ResponseTime),
+		ResponseTime: resp.ResponseTime,
+		Environment:  environment,
+		Error:        err,
+	}
+	result := probers.CheckResult(check, p.probe.Socket.Alerts)
+	if result.Failed {
+		return result
+	}
+
+	if expected := p.probe.Socket.Expected; expected != "" && !strings.Contains(resp.Data, expected) {
+		result.Failed = true
+		result.Reason = probers.ProbeStatusReason{
+			AlertQuery:   fmt.Sprintf("response.data contains %q", expected),
+			AlertMessage: "Response does not contain the expected data",
+			RequestURL:   address,
+		}
+	}
+
+	return result
+}
+
+// sendSocket connects to the service and sends the data. The response is read
+// only if data is sent or expected, until the expected data is received, or
+// else until the first data is received.
+func sendSocket(ctx context.Context, socket loader.ConfigProbeSocket) (SocketResult, error) {
+	timeout := time.Duration(socket.Timeout) * time.Millisecond
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	elapsed := func() float64 {
+		return float64(time.Since(start).Microseconds()) / 1_000
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(socket.Host, strconv.Itoa(socket.Port)))
+	if err != nil {
+		return SocketResult{ResponseTime: elapsed()}, err
+	}
+	defer conn.Close()
+
+	if socket.Data == "" && socket.Expected == "" {
+		return SocketResult{ResponseTime: elapsed()}, nil
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return SocketResult{ResponseTime: elapsed()}, err
+	}
+
+	if socket.Data != "" {
+		if _, err := io.WriteString(conn, socket.Data); err != nil {
+			return SocketResult{ResponseTime: elapsed()}, err
+		}
+	}
+
+	var data strings.Builder
+	buffer := make([]byte, 4096)
+	for data.Len() < maxResponseSize {
+		n, err := conn.Read(buffer)
+		data.Write(buffer[:n])
+
+		if socket.Expected == "" && data.Len() > 0 {
+			break
+		}
+		if socket.Expected != "" && strings.Contains(data.String(), socket.Expected) {
+			break
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			// The data received so far is checked against the expected data
+			if data.Len() > 0 {
+				break
+			}
+			return SocketResult{ResponseTime: elapsed()}, fmt.Errorf("no response received within %s", timeout)
+		}
+		if err != nil {
+			return SocketResult{Data: data.String(), ResponseTime: elapsed()}, err
+		}
+	}
+
+	return SocketResult{Data: data.String(), ResponseTime: elapsed()}, nil
+}